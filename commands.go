@@ -0,0 +1,357 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	neuron "tinybrain/metal"
+	"tinybrain/utils"
+)
+
+func runNew(args []string) error {
+	fs, spec, err := newFlagSet("new", args)
+	if err != nil {
+		return err
+	}
+	force := fs.Bool("force", false, "overwrite an existing state file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(spec.Outputs.State); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", spec.Outputs.State)
+	}
+
+	net, err := spec.Build(spec.NewRand())
+	if err != nil {
+		return err
+	}
+	if err := net.Save(spec.Outputs.State); err != nil {
+		return err
+	}
+	emit(networkEvent{"created", spec.Outputs.State, len(net.Layers)})
+	return nil
+}
+
+type evalEvent struct {
+	Event  string `json:"event"`
+	Trials int    `json:"trials"`
+	utils.ClassificationResult
+}
+
+func runEval(args []string) error {
+	fs, spec, err := newFlagSet("eval", args)
+	if err != nil {
+		return err
+	}
+	trials := fs.Int("trials", 20, "presentations of each pattern")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	patterns := spec.Plan(spec.NewRand()).Patterns()
+	if len(patterns) < 2 {
+		return fmt.Errorf("eval needs two patterns, have %d", len(patterns))
+	}
+	if *trials < 2 {
+		return fmt.Errorf("eval needs at least 2 trials")
+	}
+
+	net, err := loadNetwork(spec, nil, false)
+	if err != nil {
+		return err
+	}
+	result, err := utils.EvaluateClassification(net, patterns[0].Values, patterns[1].Values, *trials)
+	if err != nil {
+		return err
+	}
+	emit(evalEvent{"eval", *trials, result})
+	return nil
+}
+
+type layerSummary struct {
+	Event             string  `json:"event"`
+	Layer             int     `json:"layer"`
+	Neurons           int     `json:"neurons"`
+	Inputs            int     `json:"inputs"`
+	MeanWeight        float64 `json:"meanWeight"`
+	MinWeight         float64 `json:"minWeight"`
+	MaxWeight         float64 `json:"maxWeight"`
+	SaturatedWeights  float64 `json:"saturatedWeights"` // fraction within 1% of a bound
+	MeanThreshold     float64 `json:"meanThreshold"`
+	MeanAdaptive      float64 `json:"meanAdaptiveThreshold"`
+	MeanBias          float64 `json:"meanBias"`
+	MembranePotential float64 `json:"meanMembranePotential"`
+}
+
+func runInspect(args []string) error {
+	fs, spec, err := newFlagSet("inspect", args)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	net, err := loadNetwork(spec, nil, false)
+	if err != nil {
+		return err
+	}
+	for l, layer := range net.Layers {
+		s := layerSummary{Event: "layer", Layer: l, Neurons: len(layer.Neurons), MinWeight: math.Inf(1), MaxWeight: math.Inf(-1)}
+		weights, saturated := 0, 0
+		s.Inputs = layer.InputSize()
+		for _, n := range layer.Neurons {
+			s.MeanThreshold += n.Threshold
+			s.MeanAdaptive += n.AdaptiveThreshold
+			s.MeanBias += n.Bias
+			s.MembranePotential += n.MembranePotential
+			span := 0.01 * (n.MaxWeight - n.MinWeight)
+			for _, c := range n.Connections {
+				s.MeanWeight += c.Weight
+				s.MinWeight = math.Min(s.MinWeight, c.Weight)
+				s.MaxWeight = math.Max(s.MaxWeight, c.Weight)
+				if c.Weight <= n.MinWeight+span || c.Weight >= n.MaxWeight-span {
+					saturated++
+				}
+				weights++
+			}
+		}
+		if weights > 0 {
+			s.MeanWeight /= float64(weights)
+			s.SaturatedWeights = float64(saturated) / float64(weights)
+		} else {
+			s.MinWeight, s.MaxWeight = 0, 0
+		}
+		if count := float64(len(layer.Neurons)); count > 0 {
+			s.MeanThreshold /= count
+			s.MeanAdaptive /= count
+			s.MeanBias /= count
+			s.MembranePotential /= count
+		}
+		emit(s)
+	}
+	return nil
+}
+
+type pathEvent struct {
+	Event string `json:"event"`
+	Path  string `json:"path"`
+}
+
+func runPlot(args []string) error {
+	fs, spec, err := newFlagSet("plot", args)
+	if err != nil {
+		return err
+	}
+	spikes := fs.String("spikes", "tests/spike_records_patterns.aer", "AER spike file for the raster (empty to skip)")
+	binary := fs.Bool("binary", false, "the spike file is binary AER")
+	layer := fs.Int("layer", -1, "layer to plot in the raster (-1 for the output layer)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	net, err := loadNetwork(spec, nil, false)
+	if err != nil {
+		return err
+	}
+	if err := utils.GenerateWeightHeatmap(net); err != nil {
+		return err
+	}
+	emit(pathEvent{"plot", filepath.Join("visualization", "weights")})
+
+	if *spikes == "" {
+		return nil
+	}
+	if *layer < 0 {
+		*layer = len(net.Layers) - 1
+	}
+	if *layer >= len(net.Layers) {
+		return fmt.Errorf("layer %d out of range", *layer)
+	}
+	events, err := readAER(*spikes, *binary)
+	if err != nil {
+		return err
+	}
+	frames, err := neuron.Frames(events, *layer, len(net.Layers[*layer].Neurons))
+	if err != nil {
+		return err
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("%s has no spikes for layer %d", *spikes, *layer)
+	}
+
+	raster := make([][]int, len(frames))
+	for t, frame := range frames {
+		raster[t] = make([]int, len(frame))
+		for i, v := range frame {
+			raster[t][i] = int(v)
+		}
+	}
+	plan := spec.Plan(spec.NewRand())
+	if err := utils.VisualizeSpikeRaster(raster, plan.Labels(), plan.Interval()); err != nil {
+		return err
+	}
+	emit(pathEvent{"plot", filepath.Join("visualization", "spike_raster.html")})
+	return nil
+}
+
+func readAER(path string, binary bool) ([]neuron.Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return neuron.NewAERReader(file, aerFormat(binary)).ReadAll()
+}
+
+func aerFormat(binary bool) neuron.AERFormat {
+	if binary {
+		return neuron.AERBinary
+	}
+	return neuron.AERText
+}
+
+var probeVariables = map[string]neuron.Variable{
+	"potential":         neuron.Potential,
+	"threshold":         neuron.Threshold,
+	"adaptiveThreshold": neuron.AdaptiveThreshold,
+	"weight":            neuron.Weight,
+	"spike":             neuron.Spike,
+	"utilization":       neuron.Utilization,
+	"resources":         neuron.Resources,
+	"efficacy":          neuron.Efficacy,
+	"conductanceE":      neuron.ExcitatoryConductance,
+	"conductanceI":      neuron.InhibitoryConductance,
+	"dendrite":          neuron.Dendrite,
+	"noiseCurrent":      neuron.NoiseCurrent,
+}
+
+func runRecord(args []string) error {
+	fs, spec, err := newFlagSet("record", args)
+	if err != nil {
+		return err
+	}
+	spikes := fs.String("spikes", "spikes.aer", "AER file to write spikes to")
+	binary := fs.Bool("binary", false, "spike and replay files are binary AER instead of text")
+	probe := fs.String("probe", "potential", "variable to probe: potential, threshold, adaptiveThreshold, weight, spike, utilization, resources, efficacy, conductanceE, conductanceI, dendrite or noiseCurrent")
+	out := fs.String("out", "", "probe output file; .csv, .jsonl or .bin (empty to disable)")
+	every := fs.Int("every", 1, "steps between probe samples")
+	replay := fs.String("replay", "", "AER file whose spikes are replayed as input instead of the patterns")
+	replayLayer := fs.Int("replay-layer", 0, "layer of the replay file used as input")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	variable, ok := probeVariables[*probe]
+	if !ok {
+		return fmt.Errorf("unknown probe variable %q", *probe)
+	}
+	// Recording does not learn unless -lr is given explicitly
+	learn := false
+	fs.Visit(func(f *flag.Flag) { learn = learn || f.Name == "lr" })
+	if !learn {
+		spec.Learning.Rate = 0
+	}
+
+	rng := spec.NewRand()
+	net, err := loadNetwork(spec, rng, true)
+	if err != nil {
+		return err
+	}
+
+	plan := spec.Plan(rng)
+	input := func(t int) []float64 { return plan.Pattern(t).Values }
+	steps := plan.Steps
+	if *replay != "" {
+		events, err := readAER(*replay, *binary)
+		if err != nil {
+			return err
+		}
+		frames, err := neuron.Frames(events, *replayLayer, net.InputSize())
+		if err != nil {
+			return err
+		}
+		input = func(t int) []float64 { return frames[t] }
+		steps = len(frames)
+	} else if net.InputSize() != spec.Inputs {
+		return fmt.Errorf("%s reads %d inputs, but the spec's patterns have %d", spec.Outputs.State, net.InputSize(), spec.Inputs)
+	}
+
+	var sinks []neuron.Sink
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		switch strings.ToLower(filepath.Ext(*out)) {
+		case ".csv":
+			sinks = append(sinks, neuron.NewCSVSink(file))
+		case ".jsonl":
+			sinks = append(sinks, neuron.NewJSONLSink(file))
+		case ".bin":
+			sinks = append(sinks, neuron.NewBinarySink(file))
+		default:
+			return fmt.Errorf("unknown probe output format %q", filepath.Ext(*out))
+		}
+	}
+	monitor := neuron.NewMonitor(sinks...)
+	for l := range net.Layers {
+		monitor.Add(&neuron.Probe{Variable: variable, Layer: l, Interval: *every, MaxSamples: 1})
+	}
+	net.Attach(monitor)
+
+	file, err := os.Create(*spikes)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	recorder := neuron.NewAERWriter(file, aerFormat(*binary))
+	var recordErr error
+	net.OnStepEnd(func(net *neuron.Network, t int, _ []int) {
+		if recordErr == nil {
+			recordErr = recorder.Record(t, net)
+		}
+		if recordErr != nil {
+			net.Stop()
+		}
+	})
+
+	outputs, err := net.Run(steps, input, plan.LearningRate)
+	if err != nil {
+		return err
+	}
+	if recordErr != nil {
+		return recordErr
+	}
+	if err := monitor.Flush(); err != nil {
+		return err
+	}
+	if err := recorder.Flush(); err != nil {
+		return err
+	}
+
+	total := 0
+	for _, o := range outputs {
+		total += countSpikes(o)
+	}
+	emit(struct {
+		Event        string `json:"event"`
+		Steps        int    `json:"steps"`
+		OutputSpikes int    `json:"outputSpikes"`
+		Spikes       string `json:"spikes"`
+		Probes       string `json:"probes,omitempty"`
+	}{"recorded", len(outputs), total, *spikes, *out})
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"tinybrain/experiment"
+)
+
+// newFlagSet returns a flag set for a subcommand with the shared experiment
+// flags bound to a spec. The spec file named by -config is loaded before the
+// flags are parsed so that flags take precedence over it.
+func newFlagSet(name string, args []string) (*flag.FlagSet, *experiment.Spec, error) {
+	spec := experiment.Default()
+	if path := findConfigFlag(args); path != "" {
+		var err error
+		if spec, err = experiment.Load(path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.String("config", "", "experiment spec file (.json, .yaml or .yml)")
+	fs.StringVar(&spec.Outputs.State, "state", spec.Outputs.State, "network state file")
+	fs.IntVar(&spec.Run.Steps, "steps", spec.Run.Steps, "milliseconds per run")
+	fs.IntVar(&spec.Input.SwitchInterval, "switch", spec.Input.SwitchInterval, "milliseconds between pattern switches")
+	fs.Float64Var(&spec.Learning.Rate, "lr", spec.Learning.Rate, "learning rate")
+	fs.Int64Var(&spec.Seed, "seed", spec.Seed, "random seed (0 seeds from the clock)")
+	return fs, spec, nil
+}
+
+// findConfigFlag returns the value of -config or --config in args, if present
+func findConfigFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
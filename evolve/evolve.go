@@ -0,0 +1,337 @@
+// Package evolve tunes networks with a genetic algorithm: a population of
+// networks is mutated, recombined layer by layer and selected on a fitness
+// function, keeping the best genomes unchanged between generations.
+package evolve
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+
+	neuron "tinybrain/metal"
+	"tinybrain/utils"
+)
+
+// Fitness scores a network; higher is better. It receives a copy of the
+// genome, so it may train or otherwise change the network it is given.
+type Fitness func(net *neuron.Network) float64
+
+// Config controls the genetic algorithm
+type Config struct {
+	Population    int     `json:"population"`
+	Generations   int     `json:"generations"`
+	Elite         int     `json:"elite"`         // best genomes copied unchanged into the next generation
+	Tournament    int     `json:"tournament"`    // genomes compared when selecting each parent
+	CrossoverRate float64 `json:"crossoverRate"` // probability that a child mixes two parents
+	MutationRate  float64 `json:"mutationRate"`  // probability that each parameter is perturbed
+	MutationScale float64 `json:"mutationScale"` // standard deviation of the perturbation
+	Workers       int     `json:"workers"`       // parallel fitness evaluations; 0 means one per CPU
+	Seed          int64   `json:"seed"`          // 0 seeds from the clock
+	CheckpointDir string  `json:"checkpointDir"` // where the best genomes are saved; empty disables
+}
+
+// DefaultConfig returns settings that work for the small tinybrain networks
+func DefaultConfig() Config {
+	return Config{
+		Population:    16,
+		Generations:   20,
+		Elite:         2,
+		Tournament:    3,
+		CrossoverRate: 0.5,
+		MutationRate:  0.1,
+		MutationScale: 0.1,
+	}
+}
+
+// Genome is one member of the population
+type Genome struct {
+	Net     *neuron.Network
+	Fitness float64
+}
+
+// Generation reports the state of the population after it was evaluated
+type Generation struct {
+	Number      int     `json:"generation"`
+	Best        float64 `json:"best"`
+	Mean        float64 `json:"mean"`
+	Worst       float64 `json:"worst"`
+	Evaluations int     `json:"evaluations"`
+}
+
+// Evolver runs the genetic algorithm over a population
+type Evolver struct {
+	Config      Config
+	Fitness     Fitness
+	Population  []*Genome
+	Generation  int
+	evaluations int
+	rng         *rand.Rand
+}
+
+// New creates an evolver whose initial population is made by calling seed
+// with the evolver's random source. Each network gets its own noise seed so
+// the run is reproducible for a fixed Config.Seed.
+func New(cfg Config, fitness Fitness, seed func(rng *rand.Rand) (*neuron.Network, error)) (*Evolver, error) {
+	if cfg.Population < 2 {
+		return nil, fmt.Errorf("evolve: population must be at least 2, got %d", cfg.Population)
+	}
+	if cfg.Elite < 0 || cfg.Elite >= cfg.Population {
+		return nil, fmt.Errorf("evolve: elite must be between 0 and population-1, got %d", cfg.Elite)
+	}
+	if cfg.Tournament < 1 {
+		cfg.Tournament = 1
+	}
+
+	s := cfg.Seed
+	if s == 0 {
+		s = rand.Int63()
+	}
+	e := &Evolver{Config: cfg, Fitness: fitness, rng: rand.New(rand.NewSource(s))}
+	for i := 0; i < cfg.Population; i++ {
+		net, err := seed(e.rng)
+		if err != nil {
+			return nil, err
+		}
+		net.SetSeed(e.rng.Uint64())
+		e.Population = append(e.Population, &Genome{Net: net})
+	}
+	return e, nil
+}
+
+// Run evaluates and checkpoints the initial population, evolves for
+// Config.Generations generations, calling report after each one, and returns
+// the best genome found
+func (e *Evolver) Run(report func(g Generation)) (*Genome, error) {
+	if err := e.evaluate(); err != nil {
+		return nil, err
+	}
+	if err := e.checkpoint(); err != nil {
+		return nil, err
+	}
+	for g := 0; g < e.Config.Generations; g++ {
+		if err := e.Step(); err != nil {
+			return nil, err
+		}
+		if report != nil {
+			report(e.Stats())
+		}
+	}
+	return e.Best(), nil
+}
+
+// Step breeds the next generation from the current, evaluated one and
+// evaluates it
+func (e *Evolver) Step() error {
+	next := make([]*Genome, 0, len(e.Population))
+	for _, g := range e.Population[:e.Config.Elite] {
+		next = append(next, g)
+	}
+	for len(next) < len(e.Population) {
+		parent := e.selectParent()
+		child, err := parent.Net.Clone()
+		if err != nil {
+			return err
+		}
+		if e.rng.Float64() < e.Config.CrossoverRate {
+			if err := Crossover(child, e.selectParent().Net, e.rng); err != nil {
+				return err
+			}
+		}
+		Mutate(child, e.rng, e.Config.MutationRate, e.Config.MutationScale)
+		child.SetSeed(e.rng.Uint64())
+		next = append(next, &Genome{Net: child, Fitness: math.NaN()})
+	}
+
+	e.Population = next
+	e.Generation++
+	if err := e.evaluate(); err != nil {
+		return err
+	}
+	return e.checkpoint()
+}
+
+// evaluate scores every genome without a fitness yet, in parallel, and sorts
+// the population best first. It reports every genome that failed to copy.
+func (e *Evolver) evaluate() error {
+	workers := e.Config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan *Genome)
+	errs := make(chan error, len(e.Population))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range jobs {
+				net, err := g.Net.Clone()
+				if err != nil {
+					errs <- err
+					continue
+				}
+				g.Fitness = e.Fitness(net)
+			}
+		}()
+	}
+	for _, g := range e.Population {
+		if e.Generation == 0 || math.IsNaN(g.Fitness) {
+			e.evaluations++
+			jobs <- g
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+	var failed []error
+	for err := range errs {
+		failed = append(failed, err)
+	}
+	if err := errors.Join(failed...); err != nil {
+		return err
+	}
+
+	sort.SliceStable(e.Population, func(i, j int) bool {
+		return e.Population[i].Fitness > e.Population[j].Fitness
+	})
+	return nil
+}
+
+// selectParent runs a tournament and returns the fittest contestant
+func (e *Evolver) selectParent() *Genome {
+	best := e.Population[e.rng.Intn(len(e.Population))]
+	for i := 1; i < e.Config.Tournament; i++ {
+		if g := e.Population[e.rng.Intn(len(e.Population))]; g.Fitness > best.Fitness {
+			best = g
+		}
+	}
+	return best
+}
+
+// Best returns the fittest genome of the current population
+func (e *Evolver) Best() *Genome {
+	return e.Population[0]
+}
+
+// Stats summarises the current population
+func (e *Evolver) Stats() Generation {
+	g := Generation{Number: e.Generation, Best: math.Inf(-1), Worst: math.Inf(1), Evaluations: e.evaluations}
+	for _, genome := range e.Population {
+		g.Best = math.Max(g.Best, genome.Fitness)
+		g.Worst = math.Min(g.Worst, genome.Fitness)
+		g.Mean += genome.Fitness
+	}
+	g.Mean /= float64(len(e.Population))
+	return g
+}
+
+// checkpoint saves the elite of the current generation as gen_NNNN_K.json
+// and its best genome as best.json
+func (e *Evolver) checkpoint() error {
+	dir := e.Config.CheckpointDir
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for k, g := range e.Population[:max(e.Config.Elite, 1)] {
+		name := filepath.Join(dir, fmt.Sprintf("gen_%04d_%d.json", e.Generation, k))
+		if err := g.Net.Save(name); err != nil {
+			return err
+		}
+	}
+	return e.Best().Net.Save(filepath.Join(dir, "best.json"))
+}
+
+// Mutate perturbs each weight, bias, threshold and decay with probability
+// rate by Gaussian noise of the given scale, and nudges refractory periods by
+// one step. Values stay inside the neuron's bounds, and weights keep the sign
+// Dale's law gives them.
+func Mutate(net *neuron.Network, rng *rand.Rand, rate, scale float64) {
+	perturb := func(v float64) float64 {
+		if rng.Float64() < rate {
+			return v + rng.NormFloat64()*scale
+		}
+		return v
+	}
+	for _, layer := range net.Layers {
+		for i := range layer.Neurons {
+			n := &layer.Neurons[i]
+			for c := range n.Connections {
+				lo, hi := n.WeightBounds(n.Connections[c])
+				n.Connections[c].Weight = math.Max(lo, math.Min(hi, perturb(n.Connections[c].Weight)))
+			}
+			for c := range n.Recurrent {
+				lo, hi := n.WeightBounds(n.Recurrent[c])
+				n.Recurrent[c].Weight = math.Max(lo, math.Min(hi, perturb(n.Recurrent[c].Weight)))
+			}
+			n.Bias = math.Max(n.MinBias, math.Min(n.MaxBias, perturb(n.Bias)))
+			n.Threshold = math.Max(0.01, perturb(n.Threshold))
+			n.Decay = math.Max(0, math.Min(1, perturb(n.Decay)))
+			if rng.Float64() < rate {
+				n.RefractoryPeriod = max(0, n.RefractoryPeriod+rng.Intn(3)-1)
+			}
+		}
+	}
+}
+
+// Crossover replaces each layer of child with a copy of the matching layer of
+// other with probability one half, when both layers have the same shape
+func Crossover(child, other *neuron.Network, rng *rand.Rand) error {
+	donor, err := other.Clone()
+	if err != nil {
+		return err
+	}
+	for l := range child.Layers {
+		if l >= len(donor.Layers) || rng.Intn(2) == 0 || !sameShape(child.Layers[l], donor.Layers[l]) {
+			continue
+		}
+		child.Layers[l] = donor.Layers[l]
+	}
+	return nil
+}
+
+func sameShape(a, b *neuron.Layer) bool {
+	if len(a.Neurons) != len(b.Neurons) {
+		return false
+	}
+	if a.InputSize() != b.InputSize() {
+		return false
+	}
+	for i := range a.Neurons {
+		if len(a.Neurons[i].Recurrent) != len(b.Neurons[i].Recurrent) {
+			return false
+		}
+	}
+	return true
+}
+
+// SeparationFitness trains the network for steps timesteps, alternating the
+// two patterns every switchInterval steps, then scores it with the separation
+// score of utils.EvaluateClassification
+func SeparationFitness(patternA, patternB []float64, steps, switchInterval int, learningRate float64, trials int) Fitness {
+	return func(net *neuron.Network) float64 {
+		for t := 0; t < steps; t++ {
+			input := patternA
+			if (t/switchInterval)%2 == 1 {
+				input = patternB
+			}
+			if _, err := net.Forward(input, t, learningRate); err != nil {
+				return 0
+			}
+		}
+		result, err := utils.EvaluateClassification(net, patternA, patternB, trials)
+		if err != nil || math.IsNaN(result.SeparationScore) {
+			return 0
+		}
+		return result.SeparationScore
+	}
+}
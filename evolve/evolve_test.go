@@ -0,0 +1,104 @@
+package evolve
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	neuron "tinybrain/metal"
+)
+
+// small builds a two-layer network with weights drawn from rng
+func small(rng *rand.Rand) (*neuron.Network, error) {
+	var layers []*neuron.Layer
+	for _, size := range []int{4, 2} {
+		neurons := make([]neuron.SpikingNeuron, size)
+		for j := range neurons {
+			n := neuron.NewSpikingNeuron(4, 0.8+rng.Float64()*0.4, 0.8, 0, 2)
+			for i := range n.Connections {
+				n.Connections[i].Weight = rng.Float64() * 0.5
+			}
+			neurons[j] = *n
+		}
+		layers = append(layers, neuron.NewLayer(neurons))
+	}
+	return neuron.NewNetwork(layers)
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Population, cfg.Generations, cfg.Workers, cfg.Seed = 6, 3, 3, 11
+	return cfg
+}
+
+var fitness = SeparationFitness([]float64{1, 1, 0, 0}, []float64{0, 0, 1, 1}, 40, 10, 0.05, 3)
+
+func TestEvolveIsReproducible(t *testing.T) {
+	var best [2]*Genome
+	for i := range best {
+		e, err := New(testConfig(), fitness, small)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if best[i], err = e.Run(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if best[0].Fitness != best[1].Fitness {
+		t.Errorf("best fitness %v and %v from the same seed", best[0].Fitness, best[1].Fitness)
+	}
+	a, _ := json.Marshal(best[0].Net)
+	b, _ := json.Marshal(best[1].Net)
+	if string(a) != string(b) {
+		t.Error("the same seed evolved different best genomes")
+	}
+}
+
+func TestEvolveCheckpoints(t *testing.T) {
+	cfg := testConfig()
+	cfg.Generations, cfg.Elite = 1, 2
+	cfg.CheckpointDir = t.TempDir()
+	e, err := New(cfg, fitness, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []Generation
+	if _, err := e.Run(func(g Generation) { reports = append(reports, g) }); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"gen_0000_0.json", "gen_0000_1.json", "gen_0001_0.json", "gen_0001_1.json", "best.json"} {
+		if _, err := os.Stat(filepath.Join(cfg.CheckpointDir, name)); err != nil {
+			t.Errorf("missing checkpoint: %v", err)
+		}
+	}
+	if len(reports) != 1 || reports[0].Number != 1 || reports[0].Evaluations != cfg.Population+cfg.Population-cfg.Elite {
+		t.Errorf("reports %+v, want generation 1 after evaluating the elite once", reports)
+	}
+}
+
+func TestEvolveReportsEveryFailure(t *testing.T) {
+	e, err := New(testConfig(), fitness, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NaN cannot be copied through the saved state
+	for _, g := range e.Population[:2] {
+		g.Net.Layers[0].Neurons[0].Connections[0].Weight = math.NaN()
+	}
+	_, err = e.Run(nil)
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("error %v, want one for each of the two broken genomes", err)
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	for _, cfg := range []Config{{Population: 1}, {Population: 4, Elite: 4}, {Population: 4, Elite: -1}} {
+		if _, err := New(cfg, fitness, small); err == nil {
+			t.Errorf("%+v: expected an error", cfg)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"tinybrain/evolve"
+	neuron "tinybrain/metal"
+)
+
+func runEvolve(args []string) error {
+	fs, spec, err := newFlagSet("evolve", args)
+	if err != nil {
+		return err
+	}
+	cfg := evolve.DefaultConfig()
+	fs.IntVar(&cfg.Population, "population", cfg.Population, "genomes per generation")
+	fs.IntVar(&cfg.Generations, "generations", cfg.Generations, "generations to evolve")
+	fs.IntVar(&cfg.Elite, "elite", cfg.Elite, "best genomes kept unchanged each generation")
+	fs.IntVar(&cfg.Tournament, "tournament", cfg.Tournament, "tournament size for parent selection")
+	fs.Float64Var(&cfg.CrossoverRate, "crossover", cfg.CrossoverRate, "probability of layer crossover per child")
+	fs.Float64Var(&cfg.MutationRate, "mutation-rate", cfg.MutationRate, "probability of mutating each parameter")
+	fs.Float64Var(&cfg.MutationScale, "mutation-scale", cfg.MutationScale, "standard deviation of mutations")
+	fs.IntVar(&cfg.Workers, "workers", 0, "parallel fitness evaluations (0 for one per CPU)")
+	fs.StringVar(&cfg.CheckpointDir, "checkpoints", "checkpoints", "directory for the best genomes of each generation (empty to disable)")
+	trials := fs.Int("trials", 20, "presentations of each pattern when scoring")
+	out := fs.String("out", "evolved_network.json", "where to save the best network")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+	plan := spec.Plan(spec.NewRand())
+	patterns := plan.Patterns()
+	if len(patterns) < 2 {
+		return fmt.Errorf("evolve needs two patterns, have %d", len(patterns))
+	}
+	if *trials < 2 {
+		return fmt.Errorf("evolve needs at least 2 trials")
+	}
+
+	cfg.Seed = spec.Seed
+	fitness := evolve.SeparationFitness(patterns[0].Values, patterns[1].Values,
+		plan.Steps, plan.Interval(), plan.LearningRate, *trials)
+	e, err := evolve.New(cfg, fitness, func(rng *rand.Rand) (*neuron.Network, error) {
+		return spec.Build(rng)
+	})
+	if err != nil {
+		return err
+	}
+
+	best, err := e.Run(func(g evolve.Generation) {
+		emit(struct {
+			Event string `json:"event"`
+			evolve.Generation
+		}{"generation", g})
+	})
+	if err != nil {
+		return err
+	}
+	if err := best.Net.Save(*out); err != nil {
+		return err
+	}
+	emit(pathEvent{"saved", *out})
+	return nil
+}
@@ -0,0 +1,659 @@
+// Package experiment parses declarative experiment specifications and turns
+// them into a network and a run plan.
+package experiment
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"tinybrain/initializer"
+	neuron "tinybrain/metal"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes an experiment: the network to build, how it learns, what it
+// is shown and where its results go
+type Spec struct {
+	Name     string       `json:"name,omitempty"`
+	Seed     int64        `json:"seed"`         // 0 seeds from the clock
+	DT       float64      `json:"dt,omitempty"` // milliseconds per step; 0 means 1
+	Inputs   int          `json:"inputs"`
+	Layers   []LayerSpec  `json:"layers"`
+	Learning LearningSpec `json:"learning"`
+	Input    InputSpec    `json:"input"`
+	Run      RunSpec      `json:"run"`
+	Outputs  OutputSpec   `json:"outputs"`
+}
+
+// LayerSpec describes one or more identical layers. Integer parameters such
+// as the refractory period take the floor of the sampled value. The fan in
+// of a weight is the number of connections of its neuron and the fan out
+// the size of the layer; in a convolutional layer they are the size of a
+// kernel and the number of channels.
+type LayerSpec struct {
+	Repeat           int                     `json:"repeat,omitempty"` // number of layers built from this spec; 0 means 1
+	Neurons          int                     `json:"neurons"`
+	Weight           initializer.Initializer `json:"weight"`
+	Bias             initializer.Initializer `json:"bias"`
+	Threshold        initializer.Initializer `json:"threshold"`
+	Decay            initializer.Initializer `json:"decay"`
+	RefractoryPeriod initializer.Initializer `json:"refractoryPeriod"`
+	MinWeight        float64                 `json:"minWeight"`
+	MaxWeight        float64                 `json:"maxWeight"`
+	MinBias          float64                 `json:"minBias"`
+	MaxBias          float64                 `json:"maxBias"`
+
+	// Homeostasis, when set, is copied to every neuron of the layer
+	Homeostasis *neuron.Homeostasis `json:"homeostasis,omitempty"`
+	// STP, when set, gives every input connection short-term plasticity
+	STP *neuron.ShortTermPlasticity `json:"stp,omitempty"`
+	// Conductance, when set, makes the synapses of every neuron conductance-based
+	Conductance *neuron.Conductance `json:"conductance,omitempty"`
+	// Noise, when set, replaces the default membrane noise of every neuron
+	Noise *neuron.Noise `json:"noise,omitempty"`
+	// Escape, when set, makes every neuron fire stochastically
+	Escape *neuron.Escape `json:"escape,omitempty"`
+	// Constraints limit the input weights of every neuron after each step
+	Constraints []neuron.Constraint `json:"constraints,omitempty"`
+	// Structural, when set, prunes weak synapses and grows new ones
+	Structural *neuron.Structural `json:"structural,omitempty"`
+	// Connectivity, when set, wires the layer sparsely to its input
+	Connectivity *ConnectivitySpec `json:"connectivity,omitempty"`
+	// Conv, when set, makes the layer convolutional; its neuron count follows
+	// from the shapes and its neurons share one threshold, decay and
+	// refractory period drawn from the distributions
+	Conv *neuron.Convolution `json:"conv,omitempty"`
+	// Pool, when set, makes the layer spiking max pooling; neuron parameters
+	// and features do not apply
+	Pool *neuron.Pooling `json:"pool,omitempty"`
+	// Init redraws fields of every neuron once the network is built, in
+	// order, from the network's random source; the built network records
+	// them in its metadata
+	Init []InitSpec `json:"init,omitempty"`
+}
+
+// InitSpec initialises one field of a layer: "weights", "recurrent" or a
+// neuron field such as "threshold", next to the initializer's kind and
+// parameters
+type InitSpec struct {
+	Field string `json:"field"`
+	initializer.Initializer
+}
+
+// ConnectivitySpec selects how a layer is wired to its input. Pattern is one
+// of "allToAll" (the default), "probability" (P), "inDegree" (K), "oneToOne",
+// "distance" (Pre and Post grids, P, Sigma) or "edges" (Edges, with their own
+// weights).
+type ConnectivitySpec struct {
+	Pattern string          `json:"pattern"`
+	P       float64         `json:"p,omitempty"`
+	K       int             `json:"k,omitempty"`
+	Pre     neuron.Grid     `json:"pre,omitempty"`
+	Post    neuron.Grid     `json:"post,omitempty"`
+	Sigma   float64         `json:"sigma,omitempty"`
+	Edges   neuron.EdgeList `json:"edges,omitempty"`
+}
+
+// pattern returns the connectivity the spec describes
+func (c *ConnectivitySpec) pattern() (neuron.Connectivity, error) {
+	switch c.Pattern {
+	case "", "allToAll":
+		return neuron.AllToAll{}, nil
+	case "probability":
+		return neuron.FixedProbability{P: c.P}, nil
+	case "inDegree":
+		return neuron.FixedInDegree{K: c.K}, nil
+	case "oneToOne":
+		return neuron.OneToOne{}, nil
+	case "distance":
+		return neuron.DistanceDependent{Pre: c.Pre, Post: c.Post, P: c.P, Sigma: c.Sigma}, nil
+	case "edges":
+		return c.Edges, nil
+	}
+	return nil, fmt.Errorf("unknown connectivity pattern %q", c.Pattern)
+}
+
+// validateShape checks the neuron count against the convolution or pooling
+// shapes, if any
+func (l LayerSpec) validateShape() error {
+	var out neuron.Shape
+	var err error
+	switch {
+	case l.Conv != nil && l.Pool != nil:
+		return errors.New("a layer cannot be both conv and pool")
+	case l.Conv != nil:
+		out, err = l.Conv.Output()
+	case l.Pool != nil:
+		out, err = l.Pool.Output()
+		if l.Homeostasis != nil || l.STP != nil || l.Conductance != nil || l.Noise != nil || l.Escape != nil || len(l.Constraints) > 0 {
+			return errors.New("pool layers have no synapses or dynamics to configure")
+		}
+	default:
+		if l.Neurons < 1 {
+			return fmt.Errorf("neurons must be positive, got %d", l.Neurons)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if l.Connectivity != nil || l.Structural != nil {
+		return errors.New("conv and pool layers have fixed connectivity")
+	}
+	if l.Neurons != 0 && l.Neurons != out.Size() {
+		return fmt.Errorf("neurons is %d but the %dx%dx%d output has %d", l.Neurons, out.Channels, out.Height, out.Width, out.Size())
+	}
+	return nil
+}
+
+// declare adds the layer to the builder under name, reading the layer named
+// from
+func (l LayerSpec) declare(b *neuron.Builder, name, from string) error {
+	var layer *neuron.LayerBuilder
+	switch {
+	case l.Conv != nil:
+		layer = b.Conv(name, *l.Conv, l.Weight)
+	case l.Pool != nil:
+		b.Pool(name, *l.Pool)
+		return nil
+	default:
+		pattern := neuron.Connectivity(neuron.AllToAll{})
+		if l.Connectivity != nil {
+			var err error
+			if pattern, err = l.Connectivity.pattern(); err != nil {
+				return err
+			}
+		}
+		weight := l.Weight
+		if _, ok := pattern.(neuron.EdgeList); ok {
+			weight = initializer.Initializer{} // edges carry their own weights
+		}
+		layer = b.Layer(name, l.Neurons)
+		b.Project(from, name, pattern, weight)
+	}
+	layer.Threshold(l.Threshold).Decay(l.Decay).Bias(l.Bias).RefractoryPeriod(l.RefractoryPeriod).
+		WeightBounds(l.MinWeight, l.MaxWeight).
+		BiasBounds(l.MinBias, l.MaxBias).
+		With(l.features)
+	for _, init := range l.Init {
+		layer.Init(init.Field, init.Initializer)
+	}
+	return nil
+}
+
+// features sets the optional features of the spec on a built layer
+func (l LayerSpec) features(built *neuron.Layer) error {
+	if l.Homeostasis != nil {
+		built.SetHomeostasis(*l.Homeostasis)
+	}
+	if l.STP != nil {
+		built.SetShortTermPlasticity(*l.STP)
+	}
+	if l.Conductance != nil {
+		built.SetConductance(*l.Conductance)
+	}
+	if l.Noise != nil {
+		built.SetNoise(*l.Noise)
+	}
+	if l.Escape != nil {
+		built.SetEscape(*l.Escape)
+	}
+	built.Constraints = slices.Clone(l.Constraints)
+	if l.Structural != nil {
+		s := *l.Structural
+		built.Structural = &s
+	}
+	return nil
+}
+
+// LearningSpec selects the learning rule. "stdp" is the neuron's built-in
+// spike-timing rule; "none" runs with a zero learning rate.
+type LearningSpec struct {
+	Rule string  `json:"rule"`
+	Rate float64 `json:"rate"`
+}
+
+// Pattern is a labelled input vector presented to the network
+type Pattern struct {
+	Label  string    `json:"label"`
+	Values []float64 `json:"values"`
+}
+
+// InputSpec is the input schedule: patterns shown in blocks of
+// SwitchInterval milliseconds, either cycling in order or drawn at random.
+// Pattern values are input currents held for every millisecond a pattern is
+// shown, so they are scaled by the timestep like any other current.
+type InputSpec struct {
+	Patterns       []Pattern `json:"patterns"`
+	SwitchInterval int       `json:"switchInterval"`  // milliseconds
+	Order          string    `json:"order,omitempty"` // "cycle" (default) or "random"
+	// Images, when set, replaces the patterns by images read from files
+	Images *ImageSpec `json:"images,omitempty"`
+}
+
+// RunSpec is the length of a run in milliseconds, one step each at the
+// default timestep, and the number of runs
+type RunSpec struct {
+	Steps int `json:"steps"`
+	Runs  int `json:"runs"`
+}
+
+// OutputSpec names the files an experiment writes; empty paths are skipped
+type OutputSpec struct {
+	State      string `json:"state"`
+	Potentials string `json:"potentials,omitempty"`
+	Spikes     string `json:"spikes,omitempty"`
+}
+
+// Default returns the classic tinybrain experiment: 8 layers of 8 neurons
+// learning to tell two patterns apart
+func Default() *Spec {
+	return &Spec{
+		Name:   "patterns-ab",
+		Inputs: 8,
+		Layers: []LayerSpec{{
+			Repeat:           8,
+			Neurons:          8,
+			Weight:           initializer.Uniform(0.1, 0.5),
+			Bias:             initializer.Uniform(-0.3, 0.3), // Wider bias range
+			Threshold:        initializer.Uniform(1.0, 1.5),  // Slightly higher thresholds
+			Decay:            initializer.Uniform(0.7, 0.9),  // Faster decay
+			RefractoryPeriod: initializer.Uniform(2, 5),      // Longer refractory
+			MinWeight:        -1.5,                           // Expanded weight range
+			MaxWeight:        1.5,
+			MinBias:          -1,
+			MaxBias:          1,
+		}},
+		Learning: LearningSpec{Rule: "stdp", Rate: 0.05},
+		Input: InputSpec{
+			Patterns: []Pattern{
+				// Stronger, more distinct patterns
+				{Label: "A", Values: []float64{1.0, 1.0, 1.0, 1.0, 0.1, 0.1, 0.1, 0.1}}, // Left side active
+				{Label: "B", Values: []float64{0.1, 1.0, 0.1, 1.0, 0.1, 1.0, 0.1, 1.0}}, // Alternating strong/weak
+			},
+			SwitchInterval: 50,
+		},
+		Run: RunSpec{Steps: 100, Runs: 1},
+		Outputs: OutputSpec{
+			State:      "network_state.json",
+			Potentials: "tests/potential_records_patterns.csv",
+			Spikes:     "tests/spike_records_patterns.aer",
+		},
+	}
+}
+
+// Load reads a spec from a .json, .yaml or .yml file. Fields missing from the
+// file keep their Default values, except that an outputs block replaces the
+// default one as a whole: recordings it does not name are not written.
+func Load(path string) (*Spec, error) {
+	// Slices are cleared before decoding because encoding/json would merge
+	// the file's elements into the default ones instead of replacing them
+	spec := Default()
+	layers, patterns, outputs := spec.Layers, spec.Input.Patterns, spec.Outputs
+	spec.Layers, spec.Input.Patterns, spec.Outputs = nil, nil, OutputSpec{}
+
+	if err := ReadFile(path, spec); err != nil {
+		return nil, err
+	}
+	if spec.Layers == nil {
+		spec.Layers = layers
+	}
+	if spec.Input.Patterns == nil {
+		spec.Input.Patterns = patterns
+	}
+	if spec.Outputs == (OutputSpec{}) {
+		spec.Outputs = outputs
+	}
+	if spec.Input.Images != nil {
+		if err := spec.Input.Images.load(filepath.Dir(path), &spec.Input); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+// ReadFile decodes a .json, .yaml or .yml file into v, rejecting unknown
+// fields. YAML is decoded through JSON so that both formats share the json tags.
+func ReadFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".json":
+	default:
+		return fmt.Errorf("%s: unknown format, want .json, .yaml or .yml", path)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Clone returns a deep copy of the spec
+func (s *Spec) Clone() *Spec {
+	clone := *s
+	clone.Layers = make([]LayerSpec, len(s.Layers))
+	for i, l := range s.Layers {
+		clone.Layers[i] = l.clone()
+	}
+	clone.Input.Patterns = make([]Pattern, len(s.Input.Patterns))
+	for i, p := range s.Input.Patterns {
+		clone.Input.Patterns[i] = Pattern{Label: p.Label, Values: append([]float64(nil), p.Values...)}
+	}
+	clone.Input.Images = clonePointer(s.Input.Images)
+	return &clone
+}
+
+// clone returns a copy of the layer spec that shares no memory with it
+func (l LayerSpec) clone() LayerSpec {
+	l.Homeostasis = clonePointer(l.Homeostasis)
+	l.STP = clonePointer(l.STP)
+	l.Conductance = clonePointer(l.Conductance)
+	l.Noise = clonePointer(l.Noise)
+	l.Escape = clonePointer(l.Escape)
+	l.Structural = clonePointer(l.Structural)
+	l.Conv = clonePointer(l.Conv)
+	l.Pool = clonePointer(l.Pool)
+	l.Constraints = slices.Clone(l.Constraints)
+	l.Init = slices.Clone(l.Init)
+	if l.Connectivity != nil {
+		c := *l.Connectivity
+		c.Edges = slices.Clone(c.Edges)
+		l.Connectivity = &c
+	}
+	return l
+}
+
+// clonePointer copies the value p points to, keeping nil as nil
+func clonePointer[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// Save writes the spec as indented JSON
+func (s *Spec) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Validate checks that the spec describes a network and schedule that can run
+func (s *Spec) Validate() error {
+	var errs []error
+	if s.Inputs < 1 {
+		errs = append(errs, fmt.Errorf("inputs must be positive, got %d", s.Inputs))
+	}
+	if s.DT < 0 {
+		errs = append(errs, fmt.Errorf("dt must be positive, got %v", s.DT))
+	}
+	if len(s.Layers) == 0 {
+		errs = append(errs, errors.New("at least one layer is required"))
+	}
+	for i, l := range s.Layers {
+		if err := l.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("layers[%d]: %w", i, err))
+		}
+	}
+
+	switch s.Learning.Rule {
+	case "stdp", "none":
+	default:
+		errs = append(errs, fmt.Errorf("learning: unknown rule %q", s.Learning.Rule))
+	}
+
+	if len(s.Input.Patterns) == 0 {
+		errs = append(errs, errors.New("input: at least one pattern is required"))
+	}
+	for _, p := range s.Input.Patterns {
+		if len(p.Values) != s.Inputs {
+			errs = append(errs, fmt.Errorf("input: pattern %s has %d values, want %d inputs", p.Label, len(p.Values), s.Inputs))
+		}
+	}
+	if s.Input.SwitchInterval < 1 {
+		errs = append(errs, fmt.Errorf("input: switchInterval must be positive, got %d", s.Input.SwitchInterval))
+	}
+	switch s.Input.Order {
+	case "", "cycle", "random":
+	default:
+		errs = append(errs, fmt.Errorf("input: unknown order %q", s.Input.Order))
+	}
+
+	if s.Run.Steps < 0 || s.Run.Runs < 0 {
+		errs = append(errs, errors.New("run: steps and runs must not be negative"))
+	}
+	if s.Outputs.State == "" {
+		errs = append(errs, errors.New("outputs: state file is required"))
+	}
+	return errors.Join(errs...)
+}
+
+func (l LayerSpec) validate() error {
+	if l.Repeat < 0 {
+		return fmt.Errorf("repeat must not be negative, got %d", l.Repeat)
+	}
+	if err := l.validateShape(); err != nil {
+		return err
+	}
+	if l.Pool != nil {
+		return nil
+	}
+	if l.MinWeight > l.MaxWeight {
+		return fmt.Errorf("minWeight %v is above maxWeight %v", l.MinWeight, l.MaxWeight)
+	}
+	if l.MinBias > l.MaxBias {
+		return fmt.Errorf("minBias %v is above maxBias %v", l.MinBias, l.MaxBias)
+	}
+	// The bias is clamped to its bounds, which would silently flatten a
+	// distribution reaching beyond them
+	switch b := l.Bias; b.Kind {
+	case initializer.ConstantKind:
+		if b.Value < l.MinBias || b.Value > l.MaxBias {
+			return fmt.Errorf("bias %v is outside minBias %v and maxBias %v", b.Value, l.MinBias, l.MaxBias)
+		}
+	case initializer.UniformKind:
+		if b.Min < l.MinBias || b.Max > l.MaxBias {
+			return fmt.Errorf("bias range [%v, %v) is outside minBias %v and maxBias %v", b.Min, b.Max, l.MinBias, l.MaxBias)
+		}
+	}
+	if l.Homeostasis != nil {
+		if err := l.Homeostasis.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.STP != nil {
+		if err := l.STP.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Conductance != nil {
+		if err := l.Conductance.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Noise != nil {
+		if err := l.Noise.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Escape != nil {
+		if err := l.Escape.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, c := range l.Constraints {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Structural != nil {
+		if err := l.Structural.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Connectivity != nil {
+		if _, err := l.Connectivity.pattern(); err != nil {
+			return err
+		}
+	}
+	for _, init := range l.Init {
+		if err := neuron.CheckInit(init.Field, init.Initializer); err != nil {
+			return err
+		}
+	}
+	for _, param := range []struct {
+		name, field string
+		init        initializer.Initializer
+	}{
+		{"weight", neuron.Weights, l.Weight},
+		{"bias", "bias", l.Bias},
+		{"threshold", "threshold", l.Threshold},
+		{"decay", "decay", l.Decay},
+		{"refractoryPeriod", "refractoryPeriod", l.RefractoryPeriod},
+	} {
+		if err := neuron.CheckInit(param.field, param.init); err != nil {
+			return fmt.Errorf("%s: %w", param.name, err)
+		}
+	}
+	return nil
+}
+
+// NewRand returns the random source for the spec's seed
+func (s *Spec) NewRand() *rand.Rand {
+	seed := s.Seed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// Build validates the spec and creates a fresh network from it with a
+// neuron.Builder seeded from rng. Layers are named after their spec,
+// "layers[i]", with a suffix ".r" for each repeat when Repeat is above 1.
+func (s *Spec) Build(rng *rand.Rand) (*neuron.Network, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	b := neuron.NewBuilder(s.Inputs).Seed(rng.Uint64())
+	if s.DT != 0 {
+		b.TimeStep(s.DT)
+	}
+	from := neuron.Input
+	for i, spec := range s.Layers {
+		for r := 0; r < max(spec.Repeat, 1); r++ {
+			name := fmt.Sprintf("layers[%d]", i)
+			if spec.Repeat > 1 {
+				name = fmt.Sprintf("%s.%d", name, r)
+			}
+			if err := spec.declare(b, name, from); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			from = name
+		}
+	}
+	return b.Build()
+}
+
+// Plan is the run schedule derived from a spec, in steps of the spec's
+// timestep
+type Plan struct {
+	Steps        int
+	Runs         int
+	LearningRate float64
+	patterns     []Pattern // values scaled by the timestep
+	interval     int
+	order        []int // pattern index per block; nil cycles in order
+}
+
+// Plan returns the run schedule. The run length and switch interval are
+// converted from milliseconds to steps and the pattern values scaled by the
+// timestep, so that a spec drives its network alike at any dt. A random
+// pattern order is drawn from rng.
+func (s *Spec) Plan(rng *rand.Rand) *Plan {
+	dt := s.DT
+	if dt == 0 {
+		dt = neuron.DefaultDT
+	}
+	p := &Plan{
+		Steps:        int(math.Round(float64(s.Run.Steps) / dt)),
+		Runs:         max(s.Run.Runs, 1),
+		LearningRate: s.Learning.Rate,
+		patterns:     s.Input.Patterns,
+		interval:     max(int(math.Round(float64(s.Input.SwitchInterval)/dt)), 1),
+	}
+	if dt != 1 {
+		p.patterns = make([]Pattern, len(s.Input.Patterns))
+		for i, pattern := range s.Input.Patterns {
+			values := make([]float64, len(pattern.Values))
+			for j, v := range pattern.Values {
+				values[j] = v * dt
+			}
+			p.patterns[i] = Pattern{Label: pattern.Label, Values: values}
+		}
+	}
+	if s.Learning.Rule == "none" {
+		p.LearningRate = 0
+	}
+	if s.Input.Order == "random" {
+		p.order = make([]int, (p.Steps+p.interval-1)/p.interval)
+		for i := range p.order {
+			p.order[i] = rng.Intn(len(p.patterns))
+		}
+	}
+	return p
+}
+
+// Pattern returns the pattern shown at step t
+func (p *Plan) Pattern(t int) Pattern {
+	block := t / p.interval
+	if p.order != nil && block < len(p.order) {
+		return p.patterns[p.order[block]]
+	}
+	return p.patterns[block%len(p.patterns)]
+}
+
+// Patterns returns the patterns in spec order, scaled for one step
+func (p *Plan) Patterns() []Pattern {
+	return p.patterns
+}
+
+// Interval returns the number of steps each pattern is shown
+func (p *Plan) Interval() int {
+	return p.interval
+}
+
+// Labels returns the pattern labels in spec order
+func (p *Plan) Labels() []string {
+	labels := make([]string, len(p.patterns))
+	for i, pattern := range p.patterns {
+		labels[i] = pattern.Label
+	}
+	return labels
+}
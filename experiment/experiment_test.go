@@ -0,0 +1,336 @@
+package experiment
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tinybrain/initializer"
+	neuron "tinybrain/metal"
+)
+
+// writeSpec writes a spec file into a temporary directory and returns its path
+func writeSpec(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadOutputs(t *testing.T) {
+	s, err := Load(writeSpec(t, "spec.yaml", "inputs: 8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Outputs != Default().Outputs {
+		t.Errorf("no outputs block: outputs %+v, want the defaults", s.Outputs)
+	}
+
+	s, err = Load(writeSpec(t, "spec.yaml", "outputs:\n  state: other.json\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Outputs != (OutputSpec{State: "other.json"}) {
+		t.Errorf("outputs naming only the state: %+v, want no recordings", s.Outputs)
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	yaml := `
+name: tiny
+inputs: 2
+dt: 0.5
+layers:
+  - neurons: 3
+    weight: {kind: uniform, min: 0, max: 0.5}
+    bias: {kind: constant}
+    threshold: {kind: normal, mean: 1, std: 0.1}
+    decay: {kind: constant, value: 0.9}
+    refractoryPeriod: {kind: constant, value: 2}
+    maxWeight: 1
+    maxBias: 1
+    homeostasis: {targetRate: 0.02, rateTau: 100, scalingTau: 1000, thresholdTau: 500}
+input:
+  patterns: [{label: on, values: [1, 0]}]
+  switchInterval: 10
+`
+	json := `{
+  "name": "tiny", "inputs": 2, "dt": 0.5,
+  "layers": [{
+    "neurons": 3,
+    "weight": {"kind": "uniform", "min": 0, "max": 0.5},
+    "bias": {"kind": "constant"},
+    "threshold": {"kind": "normal", "mean": 1, "std": 0.1},
+    "decay": {"kind": "constant", "value": 0.9},
+    "refractoryPeriod": {"kind": "constant", "value": 2},
+    "maxWeight": 1, "maxBias": 1,
+    "homeostasis": {"targetRate": 0.02, "rateTau": 100, "scalingTau": 1000, "thresholdTau": 500}
+  }],
+  "input": {"patterns": [{"label": "on", "values": [1, 0]}], "switchInterval": 10}
+}`
+	fromYAML, err := Load(writeSpec(t, "spec.yaml", yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := Load(writeSpec(t, "spec.json", json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("YAML and JSON differ:\n%+v\n%+v", fromYAML, fromJSON)
+	}
+	if len(fromYAML.Layers) != 1 || len(fromYAML.Input.Patterns) != 1 {
+		t.Errorf("%d layers and %d patterns, want the file's to replace the defaults", len(fromYAML.Layers), len(fromYAML.Input.Patterns))
+	}
+	if fromYAML.Learning != Default().Learning || fromYAML.Run != Default().Run {
+		t.Errorf("missing blocks should keep their defaults, got %+v and %+v", fromYAML.Learning, fromYAML.Run)
+	}
+
+	if _, err := Load(writeSpec(t, "spec.yaml", "inputs: 2\nneurons: 3\n")); err == nil {
+		t.Error("unknown field: expected an error")
+	}
+	if _, err := Load(writeSpec(t, "spec.toml", "inputs = 2\n")); err == nil {
+		t.Error("unknown extension: expected an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default spec: %v", err)
+	}
+	for _, tc := range []struct {
+		name   string
+		change func(s *Spec)
+		want   string
+	}{
+		{"no inputs", func(s *Spec) { s.Inputs = 0 }, "inputs must be positive"},
+		{"negative dt", func(s *Spec) { s.DT = -1 }, "dt"},
+		{"no layers", func(s *Spec) { s.Layers = nil }, "at least one layer"},
+		{"no neurons", func(s *Spec) { s.Layers[0].Neurons = 0 }, "neurons must be positive"},
+		{"weight bounds", func(s *Spec) { s.Layers[0].MinWeight = 2 }, "minWeight"},
+		{"bias bounds", func(s *Spec) { s.Layers[0].MinBias, s.Layers[0].MaxBias = 0, 0 }, "bias range [-0.3, 0.3) is outside"},
+		{"distribution", func(s *Spec) { s.Layers[0].Decay = initializer.Uniform(1, 0) }, "decay"},
+		{"homeostasis", func(s *Spec) { s.Layers[0].Homeostasis = &neuron.Homeostasis{TargetRate: -1} }, "layers[0]"},
+		{"conv and pool", func(s *Spec) {
+			s.Layers[0].Conv = &neuron.Convolution{}
+			s.Layers[0].Pool = &neuron.Pooling{}
+		}, "both conv and pool"},
+		{"connectivity", func(s *Spec) { s.Layers[0].Connectivity = &ConnectivitySpec{Pattern: "random"} }, "connectivity"},
+		{"rule", func(s *Spec) { s.Learning.Rule = "hebb" }, "unknown rule"},
+		{"pattern width", func(s *Spec) { s.Input.Patterns[0].Values = []float64{1} }, "pattern A"},
+		{"switch interval", func(s *Spec) { s.Input.SwitchInterval = 0 }, "switchInterval"},
+		{"order", func(s *Spec) { s.Input.Order = "shuffle" }, "unknown order"},
+		{"state", func(s *Spec) { s.Outputs.State = "" }, "state file"},
+	} {
+		s := Default()
+		tc.change(s)
+		err := s.Validate()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want one mentioning %q", tc.name, err, tc.want)
+		}
+	}
+
+	// Every problem is reported at once
+	s := Default()
+	s.Inputs, s.Learning.Rule = 0, "hebb"
+	if err := s.Validate(); err == nil || !strings.Contains(err.Error(), "inputs") || !strings.Contains(err.Error(), "rule") {
+		t.Errorf("two problems: error %v, want both reported", err)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	s := Default()
+	s.DT = 0.5
+	s.Layers = append(s.Layers, LayerSpec{
+		Neurons:          2,
+		Weight:           initializer.Constant(0.2),
+		Bias:             initializer.Constant(0),
+		Threshold:        initializer.Constant(1),
+		Decay:            initializer.Constant(0.9),
+		RefractoryPeriod: initializer.Constant(1),
+		MinWeight:        -1,
+		MaxWeight:        1,
+		Homeostasis:      neuron.NewHomeostasis(0.02, 100, 1000, 500),
+		Constraints:      []neuron.Constraint{{Kind: neuron.L1Norm, Target: 1}},
+	})
+	net, err := s.Build(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Layers) != 9 || net.DT != 0.5 {
+		t.Fatalf("%d layers at dt %v, want 8 repeated and 1 more at 0.5", len(net.Layers), net.DT)
+	}
+	last := net.Layers[8]
+	if len(last.Neurons) != 2 || len(last.Neurons[0].Connections) != 8 || len(last.Constraints) != 1 {
+		t.Errorf("last layer: %d neurons reading %d inputs with %d constraints", len(last.Neurons), len(last.Neurons[0].Connections), len(last.Constraints))
+	}
+	for j, n := range last.Neurons {
+		if n.Homeostasis == nil || n.Threshold != 1 || n.MaxWeight != 1 {
+			t.Errorf("neuron %d: %+v, want the spec's constants and homeostasis", j, n)
+		}
+	}
+	if last.Neurons[0].Homeostasis == last.Neurons[1].Homeostasis || last.Neurons[0].Homeostasis == s.Layers[1].Homeostasis {
+		t.Error("neurons share the homeostasis of the spec")
+	}
+
+	again, err := s.Build(rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for l := range net.Layers {
+		if !reflect.DeepEqual(net.Layers[l].Weights(), again.Layers[l].Weights()) {
+			t.Fatalf("layer %d: the same seed built different weights", l)
+		}
+	}
+
+	s.Inputs = 0
+	if _, err := s.Build(rand.New(rand.NewSource(1))); err == nil {
+		t.Error("invalid spec: expected Build to fail")
+	}
+}
+
+func TestBuildShapesAndInit(t *testing.T) {
+	layer := func() LayerSpec {
+		return LayerSpec{
+			Weight:           initializer.Uniform(0.1, 0.3),
+			Bias:             initializer.Constant(0),
+			Threshold:        initializer.Uniform(1, 2),
+			Decay:            initializer.Constant(0.9),
+			RefractoryPeriod: initializer.Constant(0),
+			MinWeight:        -1,
+			MaxWeight:        1,
+			MinBias:          -1,
+			MaxBias:          1,
+		}
+	}
+	conv, pool, edges := layer(), layer(), layer()
+	conv.Conv = &neuron.Convolution{Input: neuron.Shape{Channels: 1, Height: 4, Width: 4}, Channels: 2, Kernel: 3, Stride: 1}
+	pool.Pool = &neuron.Pooling{Input: neuron.Shape{Channels: 2, Height: 2, Width: 2}, Size: 2, Stride: 2}
+	edges.Neurons = 2
+	edges.Connectivity = &ConnectivitySpec{Pattern: "edges", Edges: neuron.EdgeList{{Pre: 1, Post: 0, Weight: 0.6}, {Pre: 0, Post: 1, Weight: -0.2}}}
+	edges.Init = []InitSpec{{Field: "decay", Initializer: initializer.Uniform(0.8, 0.85)}}
+
+	s := Default()
+	inputs := func(n int) {
+		s.Inputs = n
+		s.Input.Patterns = []Pattern{{Label: "A", Values: make([]float64, n)}}
+	}
+	inputs(16)
+	s.Layers = []LayerSpec{conv, pool, edges}
+	net, err := s.Build(rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []int{len(net.Layers[0].Neurons), len(net.Layers[1].Neurons), len(net.Layers[2].Neurons)}; !reflect.DeepEqual(got, []int{8, 2, 2}) {
+		t.Fatalf("layer sizes %v, want 8, 2 and 2", got)
+	}
+	if net.Layers[0].Conv == nil || net.Layers[1].Pool == nil {
+		t.Error("conv and pool layers lost their shapes")
+	}
+	out := net.Layers[2].Neurons
+	if out[0].Connections[0].Weight != 0.6 || out[1].Connections[0].Weight != -0.2 {
+		t.Errorf("edge weights %v and %v, want 0.6 and -0.2", out[0].Connections[0].Weight, out[1].Connections[0].Weight)
+	}
+
+	// Every distribution drawn from is recorded and saved, but not the edge
+	// list's own weights or the pooling layer's fixed ones
+	drawn := func(l int) []neuron.InitRecord {
+		return []neuron.InitRecord{
+			{Layer: l, Field: "threshold", Initializer: initializer.Uniform(1, 2)},
+			{Layer: l, Field: "decay", Initializer: initializer.Constant(0.9)},
+			{Layer: l, Field: "bias", Initializer: initializer.Constant(0)},
+			{Layer: l, Field: "refractoryPeriod", Initializer: initializer.Constant(0)},
+		}
+	}
+	want := append(drawn(0), neuron.InitRecord{Layer: 0, Field: neuron.Weights, Initializer: initializer.Uniform(0.1, 0.3)})
+	want = append(want, drawn(2)...)
+	want = append(want, neuron.InitRecord{Layer: 2, Field: "decay", Initializer: initializer.Uniform(0.8, 0.85)})
+	path := filepath.Join(t.TempDir(), "net.json")
+	if err := net.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &neuron.Network{}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*neuron.Network{net, loaded} {
+		records, err := n.InitRecords()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("init records %+v, want %+v", records, want)
+		}
+	}
+
+	// The spec is valid on its own but the conv layer does not fit the input
+	inputs(9)
+	if _, err := s.Build(rand.New(rand.NewSource(3))); err == nil || !strings.Contains(err.Error(), "layers[0]") {
+		t.Errorf("conv over 9 inputs: got %v, want an error naming layers[0]", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	s := Default()
+	s.Layers[0].Homeostasis = neuron.NewHomeostasis(0.02, 100, 1000, 500)
+	s.Layers[0].Constraints = []neuron.Constraint{{Kind: neuron.L2Norm, Target: 1}}
+	s.Layers[0].Connectivity = &ConnectivitySpec{Pattern: "edges", Edges: neuron.EdgeList{{Pre: 0, Post: 0, Weight: 1}}}
+	s.Layers[0].Conv = &neuron.Convolution{Channels: 2}
+	s.Input.Images = &ImageSpec{Images: "a"}
+
+	c := s.Clone()
+	if !reflect.DeepEqual(s, c) {
+		t.Fatal("clone differs from the spec")
+	}
+	c.Layers[0].Homeostasis.TargetRate = 1
+	c.Layers[0].Constraints[0].Target = 2
+	c.Layers[0].Connectivity.Edges[0].Weight = 3
+	c.Layers[0].Conv.Channels = 4
+	c.Input.Images.Images = "b"
+	c.Input.Patterns[0].Values[0] = 5
+	if s.Layers[0].Homeostasis.TargetRate != 0.02 || s.Layers[0].Constraints[0].Target != 1 ||
+		s.Layers[0].Connectivity.Edges[0].Weight != 1 || s.Layers[0].Conv.Channels != 2 ||
+		s.Input.Images.Images != "a" || s.Input.Patterns[0].Values[0] != 1 {
+		t.Errorf("changing the clone changed the spec: %+v", s.Layers[0])
+	}
+}
+
+func TestPlanTimestep(t *testing.T) {
+	s := Default()
+	coarse := s.Plan(rand.New(rand.NewSource(1)))
+	s.DT = 0.1
+	fine := s.Plan(rand.New(rand.NewSource(1)))
+	if coarse.Steps != 100 || coarse.Interval() != 50 || fine.Steps != 1000 || fine.Interval() != 500 {
+		t.Fatalf("%d steps switching every %d at dt 1 and %d every %d at dt 0.1, want 100/50 and 1000/500",
+			coarse.Steps, coarse.Interval(), fine.Steps, fine.Interval())
+	}
+	if !reflect.DeepEqual(coarse.Patterns(), s.Input.Patterns) {
+		t.Error("dt 1 changed the pattern values")
+	}
+	if a, b := fine.Pattern(499).Label, fine.Pattern(500).Label; a != "A" || b != "B" {
+		t.Errorf("patterns %s and %s either side of 50 ms, want A and B", a, b)
+	}
+
+	// The drive summed over a run is the same at both timesteps
+	drive := func(p *Plan) float64 {
+		sum := 0.0
+		for step := range p.Steps {
+			for _, v := range p.Pattern(step).Values {
+				sum += v
+			}
+		}
+		return sum
+	}
+	if c, f := drive(coarse), drive(fine); math.Abs(c-f) > 1e-9 {
+		t.Errorf("drive %v at dt 1 and %v at dt 0.1", c, f)
+	}
+	if s.Input.Patterns[0].Values[0] != 1 {
+		t.Error("scaling the plan changed the spec's patterns")
+	}
+}
@@ -0,0 +1,96 @@
+package experiment
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ImageSpec reads input patterns from image files in IDX format, the format
+// of the MNIST digits, optionally gzipped. Pixels are scaled from 0-255 to
+// 0-1 and laid out row by row, matching a one-channel neuron.Shape; each
+// pattern is labelled with its class number. Relative paths are resolved
+// against the spec file.
+type ImageSpec struct {
+	Images string `json:"images"`
+	Labels string `json:"labels"`
+	Limit  int    `json:"limit,omitempty"` // read only the first images; 0 reads all
+}
+
+// load replaces the patterns of the input by the images
+func (s *ImageSpec) load(dir string, input *InputSpec) error {
+	images, dims, err := readIDX(resolve(dir, s.Images), 3)
+	if err != nil {
+		return err
+	}
+	labels, _, err := readIDX(resolve(dir, s.Labels), 1)
+	if err != nil {
+		return err
+	}
+	size := dims[1] * dims[2]
+	count := dims[0]
+	if len(labels) != count {
+		return fmt.Errorf("images: %d images but %d labels", count, len(labels))
+	}
+	if s.Limit > 0 {
+		count = min(count, s.Limit)
+	}
+
+	input.Patterns = make([]Pattern, count)
+	for i := range input.Patterns {
+		values := make([]float64, size)
+		for j, b := range images[i*size : (i+1)*size] {
+			values[j] = float64(b) / 255
+		}
+		input.Patterns[i] = Pattern{Label: strconv.Itoa(int(labels[i])), Values: values}
+	}
+	return nil
+}
+
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// readIDX reads an IDX file of unsigned bytes with the given number of
+// dimensions and returns its data and dimensions
+func readIDX(path string, rank int) ([]byte, []int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.HasSuffix(path, ".gz") {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	// The header is two zero bytes, the element type (8 for unsigned
+	// bytes), the rank and one big-endian uint32 per dimension
+	header := 4 + 4*rank
+	if len(data) < header || data[0] != 0 || data[1] != 0 || data[2] != 8 || int(data[3]) != rank {
+		return nil, nil, fmt.Errorf("%s: not an IDX file of unsigned bytes with %d dimensions", path, rank)
+	}
+	dims := make([]int, rank)
+	size := 1
+	for i := range dims {
+		dims[i] = int(binary.BigEndian.Uint32(data[4+4*i:]))
+		size *= dims[i]
+	}
+	if len(data)-header != size {
+		return nil, nil, fmt.Errorf("%s: %d bytes of data for dimensions %v", path, len(data)-header, dims)
+	}
+	return data[header:], dims, nil
+}
@@ -0,0 +1,99 @@
+package experiment
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeIDX writes an IDX file of unsigned bytes, gzipped when the path ends
+// in .gz
+func writeIDX(t *testing.T, path string, dims []int, data []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 8, byte(len(dims))})
+	for _, d := range dims {
+		binary.Write(&buf, binary.BigEndian, uint32(d))
+	}
+	buf.Write(data)
+	out := buf.Bytes()
+	if filepath.Ext(path) == ".gz" {
+		var z bytes.Buffer
+		w := gzip.NewWriter(&z)
+		w.Write(out)
+		w.Close()
+		out = z.Bytes()
+	}
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadIDX(t *testing.T) {
+	dir := t.TempDir()
+	pixels := []byte{0, 255, 51, 102, 0, 0, 255, 255}
+	for _, name := range []string{"images.idx", "images.idx.gz"} {
+		path := filepath.Join(dir, name)
+		writeIDX(t, path, []int{2, 2, 2}, pixels)
+		data, dims, err := readIDX(path, 3)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(dims) != 3 || dims[0] != 2 || dims[1] != 2 || dims[2] != 2 || !bytes.Equal(data, pixels) {
+			t.Errorf("%s: dimensions %v and data %v", name, dims, data)
+		}
+	}
+
+	short := filepath.Join(dir, "short.idx")
+	writeIDX(t, short, []int{2, 2, 2}, pixels[:7])
+	if _, _, err := readIDX(short, 3); err == nil {
+		t.Error("truncated data: expected an error")
+	}
+	if _, _, err := readIDX(filepath.Join(dir, "images.idx"), 1); err == nil {
+		t.Error("wrong rank: expected an error")
+	}
+	junk := filepath.Join(dir, "junk.idx")
+	os.WriteFile(junk, []byte("not an idx file"), 0o644)
+	if _, _, err := readIDX(junk, 3); err == nil {
+		t.Error("bad header: expected an error")
+	}
+}
+
+func TestLoadImages(t *testing.T) {
+	dir := t.TempDir()
+	writeIDX(t, filepath.Join(dir, "images.gz"), []int{3, 1, 2}, []byte{0, 255, 51, 0, 255, 255})
+	writeIDX(t, filepath.Join(dir, "labels"), []int{3}, []byte{7, 1, 4})
+	spec := []byte(`
+inputs: 2
+input:
+  images: {images: images.gz, labels: labels, limit: 2}
+`)
+	path := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(path, spec, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Pattern{
+		{Label: "7", Values: []float64{0, 1}},
+		{Label: "1", Values: []float64{0.2, 0}},
+	}
+	if len(s.Input.Patterns) != len(want) {
+		t.Fatalf("%d patterns, want %d", len(s.Input.Patterns), len(want))
+	}
+	for i, p := range s.Input.Patterns {
+		if p.Label != want[i].Label || p.Values[0] != want[i].Values[0] || p.Values[1] != want[i].Values[1] {
+			t.Errorf("pattern %d: %+v, want %+v", i, p, want[i])
+		}
+	}
+
+	writeIDX(t, filepath.Join(dir, "labels"), []int{2}, []byte{7, 1})
+	if _, err := Load(path); err == nil {
+		t.Error("fewer labels than images: expected an error")
+	}
+}
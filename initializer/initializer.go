@@ -0,0 +1,142 @@
+// Package initializer describes the distributions that neuron parameters and
+// connection weights are drawn from. An Initializer is plain data, so specs
+// and saved networks can record how a network was initialised; the network
+// builder and Layer.Initialize in package neuron draw from it.
+package initializer
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// Kinds of initializer
+const (
+	ConstantKind      = "constant"      // Value
+	UniformKind       = "uniform"       // Min to Max
+	NormalKind        = "normal"        // Mean, Std
+	LogNormalKind     = "logNormal"     // exp of a normal with Mean and Std; always positive
+	XavierUniformKind = "xavierUniform" // ±Gain·√(6/(fanIn+fanOut))
+	XavierNormalKind  = "xavierNormal"  // std Gain·√(2/(fanIn+fanOut))
+	HeUniformKind     = "heUniform"     // ±Gain·√(6/fanIn)
+	HeNormalKind      = "heNormal"      // std Gain·√(2/fanIn)
+)
+
+// Initializer describes a distribution. Xavier and He initializers scale
+// with the number of connections and only apply to weights; a Gain of 0 is 1.
+type Initializer struct {
+	Kind  string  `json:"kind"`
+	Value float64 `json:"value,omitempty"`
+	Min   float64 `json:"min,omitempty"`
+	Max   float64 `json:"max,omitempty"`
+	Mean  float64 `json:"mean,omitempty"`
+	Std   float64 `json:"std,omitempty"`
+	Gain  float64 `json:"gain,omitempty"`
+}
+
+func Constant(v float64) Initializer {
+	return Initializer{Kind: ConstantKind, Value: v}
+}
+
+func Uniform(min, max float64) Initializer {
+	return Initializer{Kind: UniformKind, Min: min, Max: max}
+}
+
+func Normal(mean, std float64) Initializer {
+	return Initializer{Kind: NormalKind, Mean: mean, Std: std}
+}
+
+func LogNormal(mean, std float64) Initializer {
+	return Initializer{Kind: LogNormalKind, Mean: mean, Std: std}
+}
+
+func XavierUniform(gain float64) Initializer {
+	return Initializer{Kind: XavierUniformKind, Gain: gain}
+}
+
+func XavierNormal(gain float64) Initializer {
+	return Initializer{Kind: XavierNormalKind, Gain: gain}
+}
+
+func HeUniform(gain float64) Initializer {
+	return Initializer{Kind: HeUniformKind, Gain: gain}
+}
+
+func HeNormal(gain float64) Initializer {
+	return Initializer{Kind: HeNormalKind, Gain: gain}
+}
+
+// Validate checks the kind and its parameters
+func (i Initializer) Validate() error {
+	switch i.Kind {
+	case ConstantKind, XavierUniformKind, XavierNormalKind, HeUniformKind, HeNormalKind:
+	case UniformKind:
+		if i.Min > i.Max {
+			return fmt.Errorf("initializer: uniform min %v is above max %v", i.Min, i.Max)
+		}
+	case NormalKind, LogNormalKind:
+		if i.Std < 0 {
+			return fmt.Errorf("initializer: %s std %v is negative", i.Kind, i.Std)
+		}
+	case "":
+		return fmt.Errorf("initializer: missing kind")
+	default:
+		return fmt.Errorf("initializer: unknown kind %q", i.Kind)
+	}
+	if i.Gain < 0 {
+		return fmt.Errorf("initializer: gain %v is negative", i.Gain)
+	}
+	return nil
+}
+
+// Scaled reports whether the initializer depends on the fan in and out, and
+// so only applies to weights
+func (i Initializer) Scaled() bool {
+	switch i.Kind {
+	case XavierUniformKind, XavierNormalKind, HeUniformKind, HeNormalKind:
+		return true
+	}
+	return false
+}
+
+// Sample draws one value for a weight with the given fan in and out, from
+// rng or the global source when nil. Parameters have no fan and pass 0.
+func (i Initializer) Sample(rng *rand.Rand, fanIn, fanOut int) float64 {
+	gain := i.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	switch i.Kind {
+	case UniformKind:
+		return i.Min + uniform(rng)*(i.Max-i.Min)
+	case NormalKind:
+		return i.Mean + normal(rng)*i.Std
+	case LogNormalKind:
+		return math.Exp(i.Mean + normal(rng)*i.Std)
+	case XavierUniformKind:
+		limit := gain * math.Sqrt(6/float64(max(fanIn+fanOut, 1)))
+		return (2*uniform(rng) - 1) * limit
+	case XavierNormalKind:
+		return normal(rng) * gain * math.Sqrt(2/float64(max(fanIn+fanOut, 1)))
+	case HeUniformKind:
+		limit := gain * math.Sqrt(6/float64(max(fanIn, 1)))
+		return (2*uniform(rng) - 1) * limit
+	case HeNormalKind:
+		return normal(rng) * gain * math.Sqrt(2/float64(max(fanIn, 1)))
+	}
+	return i.Value
+}
+
+func uniform(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
+
+func normal(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.NormFloat64()
+	}
+	return rng.NormFloat64()
+}
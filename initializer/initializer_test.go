@@ -0,0 +1,61 @@
+package initializer
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// moments returns the mean and standard deviation of n draws
+func moments(init Initializer, n, fanIn, fanOut int) (float64, float64) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	sum, squares := 0.0, 0.0
+	for range n {
+		x := init.Sample(rng, fanIn, fanOut)
+		sum += x
+		squares += x * x
+	}
+	mean := sum / float64(n)
+	return mean, math.Sqrt(squares/float64(n) - mean*mean)
+}
+
+func TestSample(t *testing.T) {
+	for _, tc := range []struct {
+		init      Initializer
+		fanIn     int
+		fanOut    int
+		mean, std float64
+	}{
+		{Constant(0.3), 0, 0, 0.3, 0},
+		{Uniform(1, 3), 0, 0, 2, 2 / math.Sqrt(12)},
+		{Normal(0.5, 0.2), 0, 0, 0.5, 0.2},
+		{LogNormal(0, 0.5), 0, 0, math.Exp(0.125), math.Sqrt((math.Exp(0.25) - 1) * math.Exp(0.25))},
+		{XavierUniform(1), 10, 20, 0, math.Sqrt(2.0 / 30)},
+		{XavierNormal(2), 10, 20, 0, 2 * math.Sqrt(2.0/30)},
+		{HeUniform(1), 50, 0, 0, math.Sqrt(2.0 / 50)},
+		{HeNormal(1), 50, 0, 0, math.Sqrt(2.0 / 50)},
+	} {
+		mean, std := moments(tc.init, 100000, tc.fanIn, tc.fanOut)
+		if math.Abs(mean-tc.mean) > 0.01 || math.Abs(std-tc.std) > 0.01 {
+			t.Errorf("%s: mean %.4f std %.4f, want %.4f and %.4f", tc.init.Kind, mean, std, tc.mean, tc.std)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, init := range []Initializer{
+		{},
+		{Kind: "beta"},
+		Uniform(1, 0),
+		Normal(0, -1),
+		LogNormal(0, -1),
+		HeNormal(-1),
+	} {
+		if err := init.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", init)
+		}
+	}
+	if err := XavierNormal(0).Validate(); err != nil {
+		t.Errorf("zero gain: %v", err)
+	}
+}
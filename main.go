@@ -1,129 +1,97 @@
+// Command tinybrain builds, trains and inspects tiny spiking networks.
+//
+// Usage:
+//
+//	tinybrain <command> [flags]
+//
+// Every command accepts -config to read an experiment spec (see package
+// experiment) from a JSON or YAML file; flags given on the command line
+// override the file. Results are written to stdout as JSON lines.
 package main
 
 import (
-	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"math/rand"
 	"os"
-	"strconv"
-	"time"
 
+	"tinybrain/experiment"
 	neuron "tinybrain/metal"
 )
 
-// createNewNetwork builds a fresh network (your existing network setup code)
-func createNewNetwork() *neuron.Network {
-	const numLayers = 8
-	const neuronsPerLayer = 8
-
-	layers := []*neuron.Layer{}
-	for i := 0; i < numLayers; i++ {
-		layer := []neuron.SpikingNeuron{}
-		inputSize := neuronsPerLayer
-		if i == 0 {
-			inputSize = 8 // input layer has 8 inputs
-		}
-		// In createNewNetwork()
-		for j := 0; j < neuronsPerLayer; j++ {
-			connections := make([]neuron.Connection, inputSize)
-			for k := range connections {
-				connections[k] = neuron.Connection{
-					Weight: 0.1 + rand.Float64()*0.4, // Random weights 0.1-0.5
-				}
-			}
-			layer = append(layer, neuron.SpikingNeuron{
-				Connections:       connections,
-				Bias:              -0.3 + rand.Float64()*0.6, // Wider bias range
-				Threshold:         1.0 + rand.Float64()*0.5,  // Slightly higher thresholds
-				Decay:             0.7 + rand.Float64()*0.2,  // Faster decay
-				RefractoryPeriod:  2 + rand.Intn(3),          // Longer refractory
-				MinWeight:         -1.5,                      // Expanded weight range
-				MaxWeight:         1.5,
-				AdaptiveThreshold: 0, // Initialize to 0
-			})
-		}
-		layers = append(layers, neuron.NewLayer(layer))
-	}
-	return neuron.NewNetwork(layers)
+var commands = []struct {
+	name, help string
+	run        func(args []string) error
+}{
+	{"new", "create a fresh network and save it", runNew},
+	{"train", "train the network on the configured patterns", runTrain},
+	{"eval", "measure how well the network separates the patterns", runEval},
+	{"inspect", "summarise weights and thresholds of a saved network", runInspect},
+	{"plot", "render spike raster and weight heatmaps", runPlot},
+	{"record", "run without saving and record spikes and probes", runRecord},
+	{"sweep", "train many parameter variations in parallel and rank them", runSweep},
+	{"evolve", "evolve network parameters with a genetic algorithm", runEvolve},
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
-
-	const timeSteps = 100
-	const patternSwitchInterval = 50 // every 10 steps, switch pattern
-	const numLayers = 8
-	const neuronsPerLayer = 8
-
-	// Try to load existing network state
-	net := &neuron.Network{}
-	err := net.Load("network_state.json")
-	if err != nil {
-		fmt.Println("No saved network, creating new one")
-		net = createNewNetwork()
-	} else {
-		fmt.Println("Loaded network from network_state.json")
-	}
-
-	// Setup CSV file for spike recording
-	file, err := os.Create("tests/spike_records_patterns.csv")
-	if err != nil {
-		panic(err)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
 	}
-	defer file.Close()
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	// CSV Headers
-	headers := []string{"t", "pattern"}
-	for l := 0; l < numLayers; l++ {
-		for n := 0; n < neuronsPerLayer; n++ {
-			headers = append(headers, fmt.Sprintf("L%d_N%d", l, n))
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		if err := cmd.run(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "tinybrain:", err)
+			os.Exit(1)
 		}
+		return
 	}
-	writer.Write(headers)
+	usage()
+	os.Exit(2)
+}
 
-	// Stronger, more distinct patterns
-	patternA := []float64{1.0, 1.0, 1.0, 1.0, 0.1, 0.1, 0.1, 0.1} // Left side active
-	patternB := []float64{0.1, 1.0, 0.1, 1.0, 0.1, 1.0, 0.1, 1.0} // Alternating strong/weak
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tinybrain <command> [flags]\n\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.help)
+	}
+	fmt.Fprintln(os.Stderr, "\nrun 'tinybrain <command> -h' for the flags of a command")
+}
 
-	for t := 0; t < timeSteps; t++ {
-		var input []float64
-		patternLabel := "A"
-		if (t/patternSwitchInterval)%2 == 0 {
-			input = patternA
-		} else {
-			input = patternB
-			patternLabel = "B"
-		}
+var output = json.NewEncoder(os.Stdout)
 
-		learningRate := 0.05
-		output := net.Forward(input, t, learningRate)
+// emit writes one structured result line to stdout
+func emit(v any) {
+	output.Encode(v)
+}
 
-		row := []string{strconv.Itoa(t), patternLabel}
-		for _, layer := range net.Layers {
-			for _, neuron := range layer.Neurons {
-				row = append(row, fmt.Sprintf("%.2f", neuron.MembranePotential))
-			}
-		}
-		writer.Write(row)
+type networkEvent struct {
+	Event  string `json:"event"`
+	State  string `json:"state"`
+	Layers int    `json:"layers"`
+}
 
-		fmt.Printf("Step %d [Pattern %s]: Output: %v\n", t, patternLabel, output)
-		// Example: track weight of first neuron in first layer from first input
-		if t%10 == 0 {
-			trackedWeight := net.Layers[0].Neurons[0].Connections[0].Weight
-			fmt.Printf("  ↳ Tracked Weight L0.N0.C0: %.4f\n", trackedWeight)
-		}
+// loadNetwork loads the saved network, building a new one from the spec if
+// there is none and create is set
+func loadNetwork(spec *experiment.Spec, rng *rand.Rand, create bool) (*neuron.Network, error) {
+	net := &neuron.Network{}
+	err := net.Load(spec.Outputs.State)
+	if err == nil {
+		emit(networkEvent{"loaded", spec.Outputs.State, len(net.Layers)})
+		return net, nil
 	}
-
-	// Save network state after training
-	if err := net.Save("network_state.json"); err != nil {
-		fmt.Println("Error saving network:", err)
-	} else {
-		fmt.Println("Network state saved to network_state.json")
+	if !create || !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
 	}
-
-	fmt.Println("Done. Check tests/spike_records_patterns.csv for potential learning traces.")
+	if net, err = spec.Build(rng); err != nil {
+		return nil, err
+	}
+	emit(networkEvent{"created", spec.Outputs.State, len(net.Layers)})
+	return net, nil
 }
 
 func clipWeights(n *neuron.SpikingNeuron, min, max float64) {
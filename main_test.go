@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tinybrain/initializer"
+	neuron "tinybrain/metal"
+)
+
+// events runs a command in a temporary directory and returns the JSON lines
+// it emitted, failing the test if the command fails
+func events(t *testing.T, run func([]string) error, args ...string) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := output
+	output = json.NewEncoder(&buf)
+	defer func() { output = saved }()
+	if err := run(args); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+
+	var result []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e map[string]any
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// named returns the events with the given name
+func named(events []map[string]any, name string) []map[string]any {
+	var result []map[string]any
+	for _, e := range events {
+		if e["event"] == name {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+func TestFindConfigFlag(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-config", "a.yaml"}, "a.yaml"},
+		{[]string{"-steps", "3", "--config=b.json"}, "b.json"},
+		{[]string{"-config"}, ""},
+		{[]string{"--", "-config", "c.yaml"}, ""},
+		{[]string{"config", "d.yaml"}, ""},
+	} {
+		if got := findConfigFlag(tc.args); got != tc.want {
+			t.Errorf("findConfigFlag(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	t.Chdir(t.TempDir())
+	state := []string{"-state", "net.json", "-seed", "1"}
+
+	created := events(t, runNew, state...)
+	if len(created) != 1 || created[0]["event"] != "created" {
+		t.Fatalf("new emitted %v", created)
+	}
+	if err := runNew(state); err == nil {
+		t.Error("new over an existing state: expected an error without -force")
+	}
+	events(t, runNew, append(state, "-force")...)
+
+	trained := events(t, runTrain, append(state, "-steps", "20", "-runs", "2", "-quiet", "-potentials", "", "-spikes", "spikes.aer")...)
+	if saved := len(named(trained, "saved")); saved != 2 {
+		t.Errorf("train emitted %d saved events over 2 runs: %v", saved, trained)
+	}
+	if _, err := os.Stat("spikes.aer"); err != nil {
+		t.Errorf("train did not write the spike file: %v", err)
+	}
+
+	layers := named(events(t, runInspect, state...), "layer")
+	if len(layers) != int(created[0]["layers"].(float64)) {
+		t.Errorf("inspect described %d layers, want %v", len(layers), created[0]["layers"])
+	}
+
+	recorded := events(t, runRecord, append(state, "-steps", "10", "-probe", "spike", "-out", "probe.csv", "-spikes", "recorded.aer")...)
+	if len(recorded) == 0 {
+		t.Error("record emitted nothing")
+	}
+	csv, err := os.ReadFile("probe.csv")
+	if err != nil || !strings.HasPrefix(string(csv), "t,probe,variable") {
+		t.Errorf("probe output %q (%v)", csv, err)
+	}
+	if err := runRecord(append(state, "-probe", "voltage")); err == nil {
+		t.Error("unknown probe variable: expected an error")
+	}
+
+	// Replayed frames take the width of the network, not of the spec
+	small, err := neuron.NewBuilder(3).Seed(1).Layer("out", 2).Done().
+		Project(neuron.Input, "out", neuron.AllToAll{}, initializer.Constant(0.5)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := small.Save("small.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("replay.aer", []byte("0 0 1\n2 0 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	replayed := named(events(t, runRecord, "-state", "small.json", "-replay", "replay.aer", "-spikes", "replayed.aer"), "recorded")
+	if len(replayed) != 1 || replayed[0]["steps"] != 3.0 {
+		t.Errorf("replay onto a 3-input network emitted %v, want 3 steps recorded", replayed)
+	}
+	if err := runRecord([]string{"-state", "small.json"}); err == nil || !strings.Contains(err.Error(), "reads 3 inputs") {
+		t.Errorf("patterns of 8 values onto a 3-input network: got %v", err)
+	}
+
+	evaluated := named(events(t, runEval, append(state, "-trials", "2")...), "eval")
+	if len(evaluated) != 1 || evaluated[0]["trials"] != 2.0 {
+		t.Errorf("eval emitted %v", evaluated)
+	}
+}
+
+func TestConfigFlagsOverrideFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "spec.yaml")
+	if err := os.WriteFile(path, []byte("run:\n  steps: 7\nlearning:\n  rate: 0.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs, spec, err := newFlagSet("test", []string{"-config", path, "-lr", "0.25"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-config", path, "-lr", "0.25"}); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Run.Steps != 7 || spec.Learning.Rate != 0.25 {
+		t.Errorf("steps %d and learning rate %v, want 7 from the file and 0.25 from the flag", spec.Run.Steps, spec.Learning.Rate)
+	}
+}
+
+func TestSweepAndEvolveCommands(t *testing.T) {
+	t.Chdir(t.TempDir())
+	sweepFile := "sweep.yaml"
+	if err := os.WriteFile(sweepFile, []byte("params:\n  - {name: threshold, values: [0.8, 1.2]}\ntrials: 2\nseed: 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	swept := events(t, runSweep, "-sweep", sweepFile, "-steps", "20", "-seed", "1", "-top", "1")
+	if trials := named(swept, "trial"); len(trials) != 1 || trials[0]["rank"] != 1.0 {
+		t.Errorf("sweep ranked %v, want only the best trial", trials)
+	}
+	if _, err := os.Stat("sweep_results.csv"); err != nil {
+		t.Errorf("sweep did not write its results: %v", err)
+	}
+	if err := runSweep([]string{"-steps", "20"}); err == nil {
+		t.Error("sweep without -sweep: expected an error")
+	}
+
+	evolved := events(t, runEvolve, "-steps", "20", "-seed", "1", "-population", "4", "-generations", "2", "-elite", "1", "-tournament", "2", "-trials", "2", "-checkpoints", "", "-out", "best.json")
+	if len(evolved) == 0 {
+		t.Error("evolve emitted nothing")
+	}
+	if _, err := os.Stat("best.json"); err != nil {
+		t.Errorf("evolve did not save the best network: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package neuron
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Event is a single Address-Event Representation (AER) spike:
+// which neuron fired, in which layer, at which timestep.
+type Event struct {
+	Time   int `json:"t"`
+	Layer  int `json:"layer"`
+	Neuron int `json:"neuron"`
+}
+
+// AERFormat selects how events are encoded on the stream
+type AERFormat int
+
+const (
+	// AERText writes one "t layer neuron" line per event
+	AERText AERFormat = iota
+	// AERBinary writes fixed 12-byte little-endian records after a magic header
+	AERBinary
+)
+
+var aerMagic = [4]byte{'A', 'E', 'R', 1}
+
+// AERWriter writes spike events to a stream
+type AERWriter struct {
+	w      *bufio.Writer
+	format AERFormat
+	header bool
+}
+
+func NewAERWriter(w io.Writer, format AERFormat) *AERWriter {
+	return &AERWriter{w: bufio.NewWriter(w), format: format}
+}
+
+// Write appends a single event
+func (a *AERWriter) Write(e Event) error {
+	if a.format == AERText {
+		_, err := fmt.Fprintf(a.w, "%d %d %d\n", e.Time, e.Layer, e.Neuron)
+		return err
+	}
+
+	if !a.header {
+		if _, err := a.w.Write(aerMagic[:]); err != nil {
+			return err
+		}
+		a.header = true
+	}
+	record := [3]int32{int32(e.Time), int32(e.Layer), int32(e.Neuron)}
+	return binary.Write(a.w, binary.LittleEndian, record)
+}
+
+// Record writes an event for every neuron of the network that fired at time t
+func (a *AERWriter) Record(t int, net *Network) error {
+	for l, layer := range net.Layers {
+		for i := range layer.Neurons {
+			if !layer.Neurons[i].Fired {
+				continue
+			}
+			if err := a.Write(Event{Time: t, Layer: l, Neuron: i}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered events to the underlying stream
+func (a *AERWriter) Flush() error {
+	return a.w.Flush()
+}
+
+// AERReader reads spike events written by AERWriter
+type AERReader struct {
+	r      *bufio.Reader
+	format AERFormat
+	header bool
+}
+
+func NewAERReader(r io.Reader, format AERFormat) *AERReader {
+	return &AERReader{r: bufio.NewReader(r), format: format}
+}
+
+// Read returns the next event, or io.EOF at the end of the stream
+func (a *AERReader) Read() (Event, error) {
+	if a.format == AERText {
+		var e Event
+		_, err := fmt.Fscanln(a.r, &e.Time, &e.Layer, &e.Neuron)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return e, err
+	}
+
+	if !a.header {
+		var magic [4]byte
+		if _, err := io.ReadFull(a.r, magic[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Event{}, errors.New("aer: truncated header")
+			}
+			return Event{}, err
+		}
+		if magic != aerMagic {
+			return Event{}, errors.New("aer: not a binary AER stream")
+		}
+		a.header = true
+	}
+	var record [3]int32
+	if err := binary.Read(a.r, binary.LittleEndian, &record); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Event{}, errors.New("aer: truncated event")
+		}
+		return Event{}, err
+	}
+	return Event{Time: int(record[0]), Layer: int(record[1]), Neuron: int(record[2])}, nil
+}
+
+// ReadAll reads events until the end of the stream
+func (a *AERReader) ReadAll() ([]Event, error) {
+	var events []Event
+	for {
+		e, err := a.Read()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// Frames turns the events of one layer into dense input vectors of the given
+// width, one per timestep from 0 up to the last event. A spike becomes 1.0.
+func Frames(events []Event, layer, width int) ([][]float64, error) {
+	steps := 0
+	for _, e := range events {
+		if e.Layer == layer && e.Time+1 > steps {
+			steps = e.Time + 1
+		}
+	}
+
+	frames := make([][]float64, steps)
+	for t := range frames {
+		frames[t] = make([]float64, width)
+	}
+	for _, e := range events {
+		if e.Layer != layer {
+			continue
+		}
+		if e.Time < 0 || e.Neuron < 0 || e.Neuron >= width {
+			return nil, fmt.Errorf("aer: event %+v out of range for width %d", e, width)
+		}
+		frames[e.Time][e.Neuron] = 1.0
+	}
+	return frames, nil
+}
+
+// Replay feeds the recorded spikes of one layer into the network as input,
+// one frame per timestep, and returns the network output for each step
+func (n *Network) Replay(events []Event, layer int, learningRate float64) ([][]int, error) {
+	if len(n.Layers) == 0 || len(n.Layers[0].Neurons) == 0 {
+		return nil, errors.New("aer: network has no input layer")
+	}
+	frames, err := Frames(events, layer, n.Layers[0].InputSize())
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([][]int, len(frames))
+	for t, input := range frames {
+		if outputs[t], err = n.Forward(input, t, learningRate); err != nil {
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
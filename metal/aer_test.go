@@ -0,0 +1,84 @@
+package neuron
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAERRoundTrip(t *testing.T) {
+	events := []Event{{0, 0, 3}, {0, 1, 0}, {2, 0, 1}, {7, 1, 12}}
+	for name, format := range map[string]AERFormat{"text": AERText, "binary": AERBinary} {
+		var buf bytes.Buffer
+		w := NewAERWriter(&buf, format)
+		for _, e := range events {
+			if err := w.Write(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := NewAERReader(bytes.NewReader(buf.Bytes()), format).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != len(events) {
+			t.Fatalf("%s: read %d events, want %d", name, len(got), len(events))
+		}
+		for i := range events {
+			if got[i] != events[i] {
+				t.Errorf("%s: event %d is %+v, want %+v", name, i, got[i], events[i])
+			}
+		}
+
+		if format == AERBinary {
+			if _, err := NewAERReader(bytes.NewReader(buf.Bytes()[:buf.Len()-2]), format).ReadAll(); err == nil {
+				t.Error("truncated binary stream: expected an error")
+			}
+		}
+	}
+	if _, err := NewAERReader(bytes.NewReader([]byte("nope, not aer")), AERBinary).Read(); err == nil {
+		t.Error("bad magic: expected an error")
+	}
+}
+
+func TestAERRecord(t *testing.T) {
+	layer := NewLayer([]SpikingNeuron{{}, {Fired: true}, {Fired: true}})
+	var buf bytes.Buffer
+	net, err := NewNetwork([]*Layer{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewAERWriter(&buf, AERText)
+	if err := w.Record(4, net); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if got, want := buf.String(), "4 0 1\n4 0 2\n"; got != want {
+		t.Errorf("recorded %q, want %q", got, want)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	events := []Event{{0, 0, 1}, {2, 0, 0}, {2, 0, 2}, {5, 1, 0}}
+	frames, err := Frames(events, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]float64{{0, 1, 0}, {0, 0, 0}, {1, 0, 1}}
+	if len(frames) != len(want) {
+		t.Fatalf("%d frames, want %d: events of other layers must not extend them", len(frames), len(want))
+	}
+	for step := range want {
+		for i := range want[step] {
+			if frames[step][i] != want[step][i] {
+				t.Fatalf("frames %v, want %v", frames, want)
+			}
+		}
+	}
+
+	if _, err := Frames([]Event{{1, 0, 3}}, 0, 3); err == nil {
+		t.Error("neuron outside the width: expected an error")
+	}
+}
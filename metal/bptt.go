@@ -0,0 +1,392 @@
+package neuron
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Surrogate stands in for the derivative of the spike step function.
+// x is the distance of the membrane potential from threshold.
+type Surrogate func(x float64) float64
+
+// FastSigmoid is the derivative of x/(1+slope|x|), peaking at 1
+func FastSigmoid(slope float64) Surrogate {
+	return func(x float64) float64 {
+		d := 1 + slope*math.Abs(x)
+		return 1 / (d * d)
+	}
+}
+
+// GaussianSurrogate is a Gaussian bump of width sigma, peaking at 1
+func GaussianSurrogate(sigma float64) Surrogate {
+	return func(x float64) float64 {
+		return math.Exp(-x * x / (2 * sigma * sigma))
+	}
+}
+
+// Arctan is the derivative of arctan(π/2·alpha·x), scaled to peak at 1
+func Arctan(alpha float64) Surrogate {
+	return func(x float64) float64 {
+		y := math.Pi / 2 * alpha * x
+		return 1 / (1 + y*y)
+	}
+}
+
+// Optimizer applies gradients to a flat parameter vector. The vector keeps
+// the same layout between calls.
+type Optimizer interface {
+	Step(params, grads []float64)
+}
+
+// SGD is stochastic gradient descent with optional momentum
+type SGD struct {
+	LearningRate float64
+	Momentum     float64
+	velocity     []float64
+}
+
+func (o *SGD) Step(params, grads []float64) {
+	if len(o.velocity) != len(params) {
+		o.velocity = make([]float64, len(params))
+	}
+	for i := range params {
+		o.velocity[i] = o.Momentum*o.velocity[i] - o.LearningRate*grads[i]
+		params[i] += o.velocity[i]
+	}
+}
+
+// Adam is the Adam optimizer. Zero Beta1, Beta2 and Epsilon take the usual
+// defaults of 0.9, 0.999 and 1e-8.
+type Adam struct {
+	LearningRate          float64
+	Beta1, Beta2, Epsilon float64
+	m, v                  []float64
+	t                     int
+}
+
+func (o *Adam) Step(params, grads []float64) {
+	if o.Beta1 == 0 {
+		o.Beta1 = 0.9
+	}
+	if o.Beta2 == 0 {
+		o.Beta2 = 0.999
+	}
+	if o.Epsilon == 0 {
+		o.Epsilon = 1e-8
+	}
+	if len(o.m) != len(params) {
+		o.m = make([]float64, len(params))
+		o.v = make([]float64, len(params))
+		o.t = 0
+	}
+
+	o.t++
+	c1 := 1 - math.Pow(o.Beta1, float64(o.t))
+	c2 := 1 - math.Pow(o.Beta2, float64(o.t))
+	for i, g := range grads {
+		o.m[i] = o.Beta1*o.m[i] + (1-o.Beta1)*g
+		o.v[i] = o.Beta2*o.v[i] + (1-o.Beta2)*g*g
+		params[i] -= o.LearningRate * (o.m[i] / c1) / (math.Sqrt(o.v[i]/c2) + o.Epsilon)
+	}
+}
+
+// TrainingSample is an input presented for a window of timesteps, one frame
+// per step, with the index of the output neuron that should fire the most
+type TrainingSample struct {
+	Frames [][]float64
+	Label  int
+}
+
+// Present repeats a static input for window steps
+func Present(input []float64, window int) [][]float64 {
+	frames := make([][]float64, window)
+	for t := range frames {
+		frames[t] = input
+	}
+	return frames
+}
+
+// BPTTTrainer trains weights and biases with backpropagation through time,
+// replacing the spike derivative by a surrogate. The output spike counts over
+// the window are the logits of a softmax cross-entropy loss.
+//
+// The trainer unrolls the deterministic part of the neuron dynamics
+// (decay, integration, threshold and reset to zero); noise, refractoriness and
+// the neurons' own online plasticity are left out, and the adaptive threshold
+// is held at its current value. Synapse and compartment options (short-term
+// plasticity, conductances, dendrites) are ignored: every connection drives
+// the soma with its weight. Recurrent connections are not unrolled: a network
+// with a non-zero recurrent weight is rejected, use EPropTrainer for those.
+// Every neuron is simulated from rest for each sample, so training does not
+// disturb the network's live membrane state.
+type BPTTTrainer struct {
+	Net       *Network
+	Surrogate Surrogate
+	Optimizer Optimizer
+}
+
+func NewBPTTTrainer(net *Network, surrogate Surrogate, optimizer Optimizer) *BPTTTrainer {
+	return &BPTTTrainer{Net: net, Surrogate: surrogate, Optimizer: optimizer}
+}
+
+// unrolled holds the history of one sample through one layer
+type unrolled struct {
+	inputs    [][]float64 // per step, the layer input
+	potential [][]float64 // per step, the potential compared with threshold
+	spikes    [][]float64 // per step, 1 for neurons that fired
+}
+
+// simulate runs the unrolled dynamics over the frames and returns the history
+// of every layer
+func (tr *BPTTTrainer) simulate(frames [][]float64) ([]unrolled, error) {
+	if len(tr.Net.Layers) == 0 {
+		return nil, errors.New("bptt: network has no layers")
+	}
+	for l, layer := range tr.Net.Layers {
+		for j, n := range layer.Neurons {
+			for _, c := range n.Recurrent {
+				if c.Weight != 0 {
+					return nil, fmt.Errorf("bptt: layer %d neuron %d has recurrent weights, which are not unrolled", l, j)
+				}
+			}
+		}
+	}
+	history := make([]unrolled, len(tr.Net.Layers))
+	potentials := make([][]float64, len(tr.Net.Layers))
+	for l, layer := range tr.Net.Layers {
+		potentials[l] = make([]float64, len(layer.Neurons))
+	}
+
+	dt := tr.Net.TimeStep()
+	for t, input := range frames {
+		for l, layer := range tr.Net.Layers {
+			v := potentials[l]
+			spikes := make([]float64, len(layer.Neurons))
+			for j := range layer.Neurons {
+				n := &layer.Neurons[j]
+				if err := n.checkInputs(len(input)); err != nil {
+					return nil, fmt.Errorf("bptt: layer %d neuron %d at step %d: %w", l, j, t, err)
+				}
+				v[j] *= kept(n.Decay, dt)
+				for i, x := range n.presynaptic(input) {
+					v[j] += x * n.Connections[i].Weight
+				}
+				v[j] += n.Bias * dt
+			}
+
+			h := &history[l]
+			h.inputs = append(h.inputs, input)
+			h.potential = append(h.potential, append([]float64(nil), v...))
+			for j := range layer.Neurons {
+				n := &layer.Neurons[j]
+				if v[j] >= n.Threshold+n.AdaptiveThreshold {
+					spikes[j] = 1
+					v[j] = 0
+				}
+			}
+			h.spikes = append(h.spikes, spikes)
+			input = spikes
+		}
+	}
+	return history, nil
+}
+
+// counts returns the output spike count of every output neuron
+func counts(history []unrolled) []float64 {
+	out := history[len(history)-1]
+	result := make([]float64, len(out.spikes[0]))
+	for _, spikes := range out.spikes {
+		for k, s := range spikes {
+			result[k] += s
+		}
+	}
+	return result
+}
+
+// Predict returns the output neuron with the most spikes over the frames
+func (tr *BPTTTrainer) Predict(frames [][]float64) (int, error) {
+	if len(frames) == 0 {
+		return 0, errors.New("bptt: no frames")
+	}
+	history, err := tr.simulate(frames)
+	if err != nil {
+		return 0, err
+	}
+	best, c := 0, counts(history)
+	for k := range c {
+		if c[k] > c[best] {
+			best = k
+		}
+	}
+	return best, nil
+}
+
+// Accuracy returns the fraction of samples that Predict labels correctly
+func (tr *BPTTTrainer) Accuracy(samples []TrainingSample) (float64, error) {
+	correct := 0
+	for _, s := range samples {
+		k, err := tr.Predict(s.Frames)
+		if err != nil {
+			return 0, err
+		}
+		if k == s.Label {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(samples)), nil
+}
+
+// TrainBatch accumulates the gradients of every sample, applies one optimizer
+// step and returns the mean loss of the batch
+func (tr *BPTTTrainer) TrainBatch(samples []TrainingSample) (float64, error) {
+	if len(samples) == 0 {
+		return 0, nil
+	}
+	params := tr.parameters()
+	grads := make([]float64, len(params))
+
+	loss := 0.0
+	for _, s := range samples {
+		l, err := tr.backward(s, grads)
+		if err != nil {
+			return 0, err
+		}
+		loss += l
+	}
+
+	values := make([]float64, len(params))
+	for i, p := range params {
+		values[i] = *p
+		grads[i] /= float64(len(samples))
+	}
+	old := make([][][]float64, len(tr.Net.Layers))
+	for l, layer := range tr.Net.Layers {
+		old[l] = layer.Weights()
+	}
+	tr.Net.track(tr.Net.Time, func() {
+		tr.Optimizer.Step(values, grads)
+		for i, p := range params {
+			*p = values[i]
+		}
+		tr.clamp()
+		for l, layer := range tr.Net.Layers {
+			layer.Constrain(old[l])
+		}
+	})
+	return loss / float64(len(samples)), nil
+}
+
+// parameters lists the trained values in a fixed order: for every neuron its
+// weights, then its bias
+func (tr *BPTTTrainer) parameters() []*float64 {
+	var params []*float64
+	for _, layer := range tr.Net.Layers {
+		for j := range layer.Neurons {
+			n := &layer.Neurons[j]
+			for i := range n.Connections {
+				params = append(params, &n.Connections[i].Weight)
+			}
+			params = append(params, &n.Bias)
+		}
+	}
+	return params
+}
+
+// clamp keeps weights and biases inside the neuron's bounds, as Forward does
+func (tr *BPTTTrainer) clamp() {
+	for _, layer := range tr.Net.Layers {
+		for j := range layer.Neurons {
+			n := &layer.Neurons[j]
+			for i := range n.Connections {
+				n.clampWeight(&n.Connections[i])
+			}
+			n.Bias = clamp(n.Bias, n.MinBias, n.MaxBias)
+		}
+	}
+}
+
+// backward adds the gradients of one sample to grads, laid out like
+// parameters, and returns its loss
+func (tr *BPTTTrainer) backward(s TrainingSample, grads []float64) (float64, error) {
+	if len(s.Frames) == 0 {
+		return 0, errors.New("bptt: sample has no frames")
+	}
+	history, err := tr.simulate(s.Frames)
+	if err != nil {
+		return 0, err
+	}
+	logits := counts(history)
+	if s.Label < 0 || s.Label >= len(logits) {
+		return 0, fmt.Errorf("bptt: label %d out of range for %d outputs", s.Label, len(logits))
+	}
+
+	// Softmax cross-entropy over spike counts
+	maxLogit := math.Inf(-1)
+	for _, c := range logits {
+		maxLogit = math.Max(maxLogit, c)
+	}
+	sum := 0.0
+	probs := make([]float64, len(logits))
+	for k, c := range logits {
+		probs[k] = math.Exp(c - maxLogit)
+		sum += probs[k]
+	}
+	for k := range probs {
+		probs[k] /= sum
+	}
+	loss := -math.Log(math.Max(probs[s.Label], 1e-12))
+
+	// Every output spike adds one to its count, so the gradient with respect
+	// to each output spike is the same at every step
+	steps, dt := len(s.Frames), tr.Net.TimeStep()
+	gradSpikes := make([][]float64, steps)
+	for t := range gradSpikes {
+		gradSpikes[t] = make([]float64, len(probs))
+		for k, p := range probs {
+			gradSpikes[t][k] = p
+		}
+		gradSpikes[t][s.Label] -= 1
+	}
+
+	// Offsets of each layer's parameters in the flat layout
+	offsets := make([]int, len(tr.Net.Layers))
+	offset := 0
+	for l, layer := range tr.Net.Layers {
+		offsets[l] = offset
+		for _, n := range layer.Neurons {
+			offset += len(n.Connections) + 1
+		}
+	}
+
+	for l := len(tr.Net.Layers) - 1; l >= 0; l-- {
+		layer, h := tr.Net.Layers[l], history[l]
+		gradInputs := make([][]float64, steps)
+		for t := range gradInputs {
+			gradInputs[t] = make([]float64, len(h.inputs[t]))
+		}
+
+		p := offsets[l]
+		for j := range layer.Neurons {
+			n := &layer.Neurons[j]
+			threshold := n.Threshold + n.AdaptiveThreshold
+			decay := kept(n.Decay, dt)
+
+			// The reset is treated as a constant, so gradient flows back in
+			// time only through the decay of a neuron that did not spike
+			carry := 0.0
+			for t := steps - 1; t >= 0; t-- {
+				delta := gradSpikes[t][j]*tr.Surrogate(h.potential[t][j]-threshold) + carry*(1-h.spikes[t][j])
+				for i, x := range n.presynaptic(h.inputs[t]) {
+					grads[p+i] += delta * x
+					gradInputs[t][n.InputIndex(i)] += delta * n.Connections[i].Weight
+				}
+				grads[p+len(n.Connections)] += delta * dt
+				carry = delta * decay
+			}
+			p += len(n.Connections) + 1
+		}
+		gradSpikes = gradInputs
+	}
+	return loss, nil
+}
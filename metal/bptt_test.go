@@ -0,0 +1,131 @@
+package neuron
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// separableDataset returns noisy copies of one prototype per class
+func separableDataset(rng *rand.Rand, classes, inputs, perClass, window int) []TrainingSample {
+	prototypes := make([][]float64, classes)
+	for c := range prototypes {
+		prototypes[c] = make([]float64, inputs)
+		for i := range prototypes[c] {
+			if i%classes == c {
+				prototypes[c][i] = 1
+			}
+		}
+	}
+
+	var samples []TrainingSample
+	for n := 0; n < perClass; n++ {
+		for c, proto := range prototypes {
+			input := make([]float64, inputs)
+			for i, v := range proto {
+				input[i] = v + rng.Float64()*0.3
+			}
+			samples = append(samples, TrainingSample{Frames: Present(input, window), Label: c})
+		}
+	}
+	return samples
+}
+
+func denseNetwork(t *testing.T, rng *rand.Rand, sizes ...int) *Network {
+	t.Helper()
+	var layers []*Layer
+	for l := 1; l < len(sizes); l++ {
+		neurons := make([]SpikingNeuron, sizes[l])
+		for j := range neurons {
+			n := NewSpikingNeuron(sizes[l-1], 1.0, 0.8, 0, 0)
+			for i := range n.Connections {
+				n.Connections[i].Weight = rng.Float64() * 0.5
+			}
+			neurons[j] = *n
+		}
+		layers = append(layers, NewLayer(neurons))
+	}
+	net, err := NewNetwork(layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func TestBPTTLearnsSeparableDataset(t *testing.T) {
+	surrogates := map[string]Surrogate{
+		"fastSigmoid": FastSigmoid(2),
+		"gaussian":    GaussianSurrogate(0.5),
+		"arctan":      Arctan(2),
+	}
+	for name, surrogate := range surrogates {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			train := separableDataset(rng, 3, 9, 20, 10)
+			test := separableDataset(rng, 3, 9, 10, 10)
+
+			trainer := NewBPTTTrainer(denseNetwork(t, rng, 9, 16, 3), surrogate, &Adam{LearningRate: 0.02})
+			for epoch := 0; epoch < 40; epoch++ {
+				rng.Shuffle(len(train), func(i, j int) { train[i], train[j] = train[j], train[i] })
+				for b := 0; b < len(train); b += 10 {
+					if _, err := trainer.TrainBatch(train[b:min(b+10, len(train))]); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			acc, err := trainer.Accuracy(test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if acc < 0.9 {
+				t.Errorf("accuracy = %.2f, want at least 0.9", acc)
+			}
+		})
+	}
+}
+
+func TestBPTTSGDReducesLoss(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	samples := separableDataset(rng, 2, 4, 10, 8)
+	trainer := NewBPTTTrainer(denseNetwork(t, rng, 4, 2), FastSigmoid(2), &SGD{LearningRate: 0.05, Momentum: 0.9})
+
+	first, err := trainer.TrainBatch(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := first
+	for i := 0; i < 50; i++ {
+		if last, err = trainer.TrainBatch(samples); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if last >= first {
+		t.Errorf("loss went from %.3f to %.3f, want it to fall", first, last)
+	}
+}
+
+func TestBPTTRejectsBadLabel(t *testing.T) {
+	trainer := NewBPTTTrainer(denseNetwork(t, rand.New(rand.NewSource(3)), 2, 2), FastSigmoid(2), &SGD{LearningRate: 0.1})
+	_, err := trainer.TrainBatch([]TrainingSample{{Frames: Present([]float64{1, 0}, 3), Label: 5}})
+	if err == nil {
+		t.Fatal("expected an error for an out of range label")
+	}
+}
+
+func TestBPTTRejectsRecurrentWeights(t *testing.T) {
+	net := denseNetwork(t, rand.New(rand.NewSource(5)), 2, 3)
+	net.Layers[0].Connect(func(post, pre int) float64 { return 0 })
+	trainer := NewBPTTTrainer(net, FastSigmoid(2), &SGD{LearningRate: 0.1})
+	samples := []TrainingSample{{Frames: Present([]float64{1, 0}, 3), Label: 1}}
+	if _, err := trainer.TrainBatch(samples); err != nil {
+		t.Fatalf("zero recurrent weights: %v", err)
+	}
+
+	net.Layers[0].Neurons[1].Recurrent[0].Weight = 0.5
+	if _, err := trainer.TrainBatch(samples); err == nil {
+		t.Error("non-zero recurrent weight: expected an error")
+	}
+	if _, err := trainer.Predict(samples[0].Frames); err == nil {
+		t.Error("non-zero recurrent weight: expected an error from Predict")
+	}
+}
@@ -0,0 +1,470 @@
+package neuron
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"tinybrain/initializer"
+)
+
+// Input names the network input in projections
+const Input = "input"
+
+// Builder declares a network: its input width, named layers in feedforward
+// order and the projections between them. Mistakes are collected and
+// reported together by Build, so calls can be chained:
+//
+//	net, err := NewBuilder(8).Seed(1).
+//		Layer("hidden", 16).Threshold(initializer.Uniform(1, 1.5)).Done().
+//		Layer("out", 2).Init("decay", initializer.Normal(0.9, 0.02)).Done().
+//		Project(Input, "hidden", FixedProbability{P: 0.5}, initializer.HeUniform(1)).
+//		Project("hidden", "hidden", FixedInDegree{K: 4}, initializer.Normal(0, 0.1)).
+//		Project("hidden", "out", AllToAll{}, initializer.Constant(0.3)).
+//		Build()
+//
+// A layer reads one feedforward projection, from the layer declared before
+// it or from the input for the first layer, and at most one recurrent
+// projection from itself. Convolutional and pooling layers read the layer
+// before them through their fixed connectivity instead.
+type Builder struct {
+	inputs      int
+	seed        uint64
+	dt          float64
+	layers      []*LayerBuilder
+	projections []projection
+	errs        []error
+}
+
+type projection struct {
+	from, to string
+	pattern  Connectivity
+	weight   initializer.Initializer
+}
+
+// NewBuilder starts a network reading inputs values per step
+func NewBuilder(inputs int) *Builder {
+	b := &Builder{inputs: inputs}
+	if inputs < 1 {
+		b.errs = append(b.errs, fmt.Errorf("builder: inputs must be positive, got %d", inputs))
+	}
+	return b
+}
+
+// Seed sets the network's seed. Building draws from the seeded source, so
+// the same declaration and seed build the same network; without a seed the
+// global source is used.
+func (b *Builder) Seed(seed uint64) *Builder {
+	b.seed = seed
+	return b
+}
+
+// TimeStep sets the network's timestep in milliseconds
+func (b *Builder) TimeStep(dt float64) *Builder {
+	if dt <= 0 || validateDT(dt) != nil {
+		b.errs = append(b.errs, fmt.Errorf("builder: timestep must be a positive number of milliseconds, got %v", dt))
+	}
+	b.dt = dt
+	return b
+}
+
+// Layer declares a layer of size neurons after the ones declared so far.
+// Unset parameters take a threshold of 1, a decay of 0.9, no bias or
+// refractory period and the bounds of NewSpikingNeuron.
+func (b *Builder) Layer(name string, size int) *LayerBuilder {
+	l := b.declare(name, size)
+	if size < 1 {
+		b.errs = append(b.errs, fmt.Errorf("builder: layer %q needs at least one neuron, got %d", name, size))
+	}
+	return l
+}
+
+// Conv declares a convolutional layer reading the layer declared before it,
+// or the input, through the kernels of conv. Its neurons share one set of
+// parameters drawn from the layer's distributions, and each kernel weight is
+// drawn from weight with the kernel size as fan in and the channels as fan
+// out. The connectivity is fixed, so no projection may target the layer.
+func (b *Builder) Conv(name string, conv Convolution, weight initializer.Initializer) *LayerBuilder {
+	out, err := conv.Output()
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("builder: layer %q: %w", name, err))
+	}
+	if err := CheckInit(Weights, weight); err != nil {
+		b.errs = append(b.errs, fmt.Errorf("builder: layer %q: %w", name, err))
+	}
+	l := b.declare(name, out.Size())
+	l.conv, l.weight = &conv, weight
+	return l
+}
+
+// Pool declares a spiking max pooling layer reading the layer declared before
+// it, or the input. Its neurons and connections are fixed, so parameters set
+// on it are ignored and no projection may target it.
+func (b *Builder) Pool(name string, pool Pooling) *LayerBuilder {
+	out, err := pool.Output()
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("builder: layer %q: %w", name, err))
+	}
+	l := b.declare(name, out.Size())
+	l.pool = &pool
+	return l
+}
+
+// declare appends a layer with the default parameters
+func (b *Builder) declare(name string, size int) *LayerBuilder {
+	l := &LayerBuilder{
+		b:         b,
+		name:      name,
+		size:      size,
+		threshold: initializer.Constant(1),
+		decay:     initializer.Constant(0.9),
+		bias:      initializer.Constant(0),
+		refractor: initializer.Constant(0),
+		minWeight: -1.5,
+		maxWeight: 1.5,
+		minBias:   -1,
+		maxBias:   1,
+	}
+	switch {
+	case name == Input:
+		b.errs = append(b.errs, fmt.Errorf("builder: layer name %q is reserved for the input", Input))
+	case b.layer(name) != nil:
+		b.errs = append(b.errs, fmt.Errorf("builder: duplicate layer %q", name))
+	}
+	b.layers = append(b.layers, l)
+	return l
+}
+
+// Project connects from (a layer or Input) to a layer with the given pattern
+// and weights. The fan in of a weight is the number of connections of its
+// neuron in the projection and the fan out the size of the layer. An EdgeList
+// keeps its own weights when weight is left zero.
+func (b *Builder) Project(from, to string, pattern Connectivity, weight initializer.Initializer) *Builder {
+	b.projections = append(b.projections, projection{from: from, to: to, pattern: pattern, weight: weight})
+	return b
+}
+
+func (b *Builder) layer(name string) *LayerBuilder {
+	for _, l := range b.layers {
+		if l.name == name {
+			return l
+		}
+	}
+	return nil
+}
+
+// index returns the position of a layer, -1 for the input and -2 for an
+// unknown name
+func (b *Builder) index(name string) int {
+	if name == Input {
+		return -1
+	}
+	for i, l := range b.layers {
+		if l.name == name {
+			return i
+		}
+	}
+	return -2
+}
+
+// Build checks the declaration and returns the network, or every problem
+// found. The network's metadata records every initializer the layers were
+// drawn from, as InitRecords.
+func (b *Builder) Build() (*Network, error) {
+	errs := append([]error(nil), b.errs...)
+	if len(b.layers) == 0 {
+		errs = append(errs, errors.New("builder: at least one layer is required"))
+	}
+
+	feedforward := make([]*projection, len(b.layers))
+	recurrent := make([]*projection, len(b.layers))
+	for i := range b.projections {
+		p := &b.projections[i]
+		from, to := b.index(p.from), b.index(p.to)
+		switch {
+		case from == -2 || to < 0:
+			errs = append(errs, fmt.Errorf("builder: projection %s->%s names an unknown layer", p.from, p.to))
+		case p.pattern == nil:
+			errs = append(errs, fmt.Errorf("builder: projection %s->%s needs a pattern", p.from, p.to))
+		case p.weight.Kind == "" && !edges(p.pattern):
+			errs = append(errs, fmt.Errorf("builder: projection %s->%s needs a weight initializer", p.from, p.to))
+		case p.weight.Kind != "" && p.weight.Validate() != nil:
+			errs = append(errs, fmt.Errorf("builder: projection %s->%s: %w", p.from, p.to, p.weight.Validate()))
+		case b.layers[to].fixed():
+			errs = append(errs, fmt.Errorf("builder: projection %s->%s targets a layer with fixed connectivity", p.from, p.to))
+		case from == to:
+			if recurrent[to] != nil {
+				errs = append(errs, fmt.Errorf("builder: layer %q has two recurrent projections", p.to))
+			}
+			recurrent[to] = p
+		case from == to-1:
+			if feedforward[to] != nil {
+				errs = append(errs, fmt.Errorf("builder: layer %q has two feedforward projections", p.to))
+			}
+			feedforward[to] = p
+		default:
+			below := Input
+			if to > 0 {
+				below = b.layers[to-1].name
+			}
+			errs = append(errs, fmt.Errorf("builder: projection %s->%s skips layers; %q can only read %q or itself", p.from, p.to, p.to, below))
+		}
+	}
+	width := b.inputs
+	for i, l := range b.layers {
+		var shape *Shape
+		switch {
+		case l.conv != nil:
+			shape = &l.conv.Input
+		case l.pool != nil:
+			shape = &l.pool.Input
+		}
+		if shape != nil && shape.Size() != width {
+			errs = append(errs, fmt.Errorf("builder: layer %q reads a %dx%dx%d input of %d values from %d", l.name, shape.Channels, shape.Height, shape.Width, shape.Size(), width))
+		}
+		width = l.size
+		for _, param := range []struct {
+			name string
+			init initializer.Initializer
+		}{
+			{"threshold", l.threshold},
+			{"decay", l.decay},
+			{"bias", l.bias},
+			{"refractoryPeriod", l.refractor},
+		} {
+			if err := CheckInit(param.name, param.init); err != nil {
+				errs = append(errs, fmt.Errorf("builder: layer %q %s: %w", l.name, param.name, err))
+			}
+		}
+		if feedforward[i] == nil && l.size > 0 && !l.fixed() {
+			errs = append(errs, fmt.Errorf("builder: layer %q has no feedforward projection", l.name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	net := &Network{DT: b.dt}
+	net.SetSeed(b.seed)
+	rng := net.Rand()
+	width = b.inputs
+	for i, l := range b.layers {
+		layer, err := l.build(width, feedforward[i], rng)
+		if err != nil {
+			return nil, err
+		}
+		if r := recurrent[i]; r != nil {
+			if err := layer.wireRecurrent(r.pattern, r.weight, rng); err != nil {
+				return nil, fmt.Errorf("builder: projection %s->%s: %w", r.from, r.to, err)
+			}
+		}
+		for j := range layer.Neurons {
+			n := &layer.Neurons[j]
+			for k := range n.Connections {
+				n.clampWeight(&n.Connections[k])
+			}
+		}
+		for _, fn := range l.with {
+			if err := fn(layer); err != nil {
+				return nil, fmt.Errorf("builder: layer %q: %w", l.name, err)
+			}
+		}
+		net.Layers = append(net.Layers, layer)
+		if err := net.record(l.records(i, feedforward[i], recurrent[i])...); err != nil {
+			return nil, err
+		}
+		for _, init := range l.inits {
+			if err := net.Initialize(i, init.Field, init.Initializer); err != nil {
+				return nil, fmt.Errorf("builder: layer %q: %w", l.name, err)
+			}
+		}
+		width = l.size
+	}
+	if err := net.Validate(); err != nil {
+		return nil, fmt.Errorf("builder: %w", err)
+	}
+	return net, nil
+}
+
+// wireRecurrent connects the layer to itself with the pattern, drawing the
+// weights of the connections it lists from init; the others stay zero
+func (l *Layer) wireRecurrent(pattern Connectivity, init initializer.Initializer, rng *rand.Rand) error {
+	size := len(l.Neurons)
+	sources, err := pattern.Sources(size, size, rng)
+	if err != nil {
+		return err
+	}
+	l.Connect(func(post, pre int) float64 { return 0 })
+	for post, pres := range sources {
+		n := &l.Neurons[post]
+		for _, pre := range pres {
+			n.Recurrent[pre].Weight = init.Sample(rng, len(pres), size)
+			n.clampWeight(&n.Recurrent[pre])
+		}
+	}
+	return nil
+}
+
+// build creates the layer over an input of the given width, wired by its
+// feedforward projection p unless its connectivity is fixed
+func (l *LayerBuilder) build(width int, p *projection, rng *rand.Rand) (*Layer, error) {
+	switch {
+	case l.conv != nil:
+		fanIn := l.conv.Input.Channels * l.conv.Kernel * l.conv.Kernel
+		weight := func(channel, i int) float64 { return l.weight.Sample(rng, fanIn, l.conv.Channels) }
+		return NewConvLayer(*l.conv, l.neuron(rng), weight)
+	case l.pool != nil:
+		return NewPoolLayer(*l.pool)
+	}
+	neurons := make([]SpikingNeuron, l.size)
+	for j := range neurons {
+		neurons[j] = l.neuron(rng)
+	}
+	layer := NewLayer(neurons)
+	weight := func(pre, post int) float64 { return 0 }
+	if edges, ok := p.pattern.(EdgeList); ok && p.weight.Kind == "" {
+		weight = edges.Weights()
+	}
+	if err := layer.Wire(width, p.pattern, weight, rng); err != nil {
+		return nil, fmt.Errorf("builder: projection %s->%s: %w", p.from, p.to, err)
+	}
+	if p.weight.Kind != "" {
+		if err := layer.Initialize(Weights, p.weight, rng); err != nil {
+			return nil, fmt.Errorf("builder: projection %s->%s: %w", p.from, p.to, err)
+		}
+	}
+	return layer, nil
+}
+
+// records lists the initializers build and wireRecurrent drew layer i from,
+// in the order they were applied
+func (l *LayerBuilder) records(i int, feedforward, recurrent *projection) []InitRecord {
+	if l.pool != nil {
+		return nil
+	}
+	records := []InitRecord{
+		{Layer: i, Field: "threshold", Initializer: l.threshold},
+		{Layer: i, Field: "decay", Initializer: l.decay},
+		{Layer: i, Field: "bias", Initializer: l.bias},
+		{Layer: i, Field: "refractoryPeriod", Initializer: l.refractor},
+	}
+	switch {
+	case l.conv != nil:
+		records = append(records, InitRecord{Layer: i, Field: Weights, Initializer: l.weight})
+	case feedforward.weight.Kind != "":
+		records = append(records, InitRecord{Layer: i, Field: Weights, Initializer: feedforward.weight})
+	}
+	if recurrent != nil && recurrent.weight.Kind != "" {
+		records = append(records, InitRecord{Layer: i, Field: Recurrent, Initializer: recurrent.weight})
+	}
+	return records
+}
+
+// fixed reports whether the layer's connectivity follows from its shapes
+func (l *LayerBuilder) fixed() bool {
+	return l.conv != nil || l.pool != nil
+}
+
+// edges reports whether a pattern lists its own weights
+func edges(pattern Connectivity) bool {
+	_, ok := pattern.(EdgeList)
+	return ok
+}
+
+// LayerBuilder sets the parameters of a declared layer. Each neuron draws
+// its own values from the initializers.
+type LayerBuilder struct {
+	b    *Builder
+	name string
+	size int
+
+	threshold, decay, bias, refractor initializer.Initializer
+	conv                              *Convolution
+	pool                              *Pooling
+	weight                            initializer.Initializer // of the kernels
+	minWeight, maxWeight              float64
+	minBias, maxBias                  float64
+	inits                             []InitRecord
+	with                              []func(*Layer) error
+}
+
+// Threshold sets the distribution of base thresholds
+func (l *LayerBuilder) Threshold(init initializer.Initializer) *LayerBuilder {
+	l.threshold = init
+	return l
+}
+
+// Decay sets the distribution of membrane decays
+func (l *LayerBuilder) Decay(init initializer.Initializer) *LayerBuilder {
+	l.decay = init
+	return l
+}
+
+// Bias sets the distribution of biases
+func (l *LayerBuilder) Bias(init initializer.Initializer) *LayerBuilder {
+	l.bias = init
+	return l
+}
+
+// RefractoryPeriod sets the distribution of refractory periods, floored to
+// whole steps
+func (l *LayerBuilder) RefractoryPeriod(init initializer.Initializer) *LayerBuilder {
+	l.refractor = init
+	return l
+}
+
+// Init redraws a field of the built layer with Network.Initialize once its
+// projections are wired, so the network's metadata records it. Inits run in
+// order, after With.
+func (l *LayerBuilder) Init(field string, init initializer.Initializer) *LayerBuilder {
+	if err := CheckInit(field, init); err != nil {
+		l.b.errs = append(l.b.errs, fmt.Errorf("builder: layer %q: %w", l.name, err))
+	}
+	l.inits = append(l.inits, InitRecord{Field: field, Initializer: init})
+	return l
+}
+
+// WeightBounds sets the range of every connection weight
+func (l *LayerBuilder) WeightBounds(min, max float64) *LayerBuilder {
+	if min > max {
+		l.b.errs = append(l.b.errs, fmt.Errorf("builder: layer %q: min weight %v is above max weight %v", l.name, min, max))
+	}
+	l.minWeight, l.maxWeight = min, max
+	return l
+}
+
+// BiasBounds sets the range of the bias
+func (l *LayerBuilder) BiasBounds(min, max float64) *LayerBuilder {
+	if min > max {
+		l.b.errs = append(l.b.errs, fmt.Errorf("builder: layer %q: min bias %v is above max bias %v", l.name, min, max))
+	}
+	l.minBias, l.maxBias = min, max
+	return l
+}
+
+// With runs fn on the built layer, after its projections are wired, to set
+// features such as homeostasis or short-term plasticity
+func (l *LayerBuilder) With(fn func(*Layer) error) *LayerBuilder {
+	l.with = append(l.with, fn)
+	return l
+}
+
+// Done returns to the network declaration
+func (l *LayerBuilder) Done() *Builder {
+	return l.b
+}
+
+// neuron draws the parameters of one neuron
+func (l *LayerBuilder) neuron(rng *rand.Rand) SpikingNeuron {
+	return SpikingNeuron{
+		Threshold:        l.threshold.Sample(rng, 0, 0),
+		Decay:            l.decay.Sample(rng, 0, 0),
+		Bias:             clamp(l.bias.Sample(rng, 0, 0), l.minBias, l.maxBias),
+		RefractoryPeriod: int(math.Floor(l.refractor.Sample(rng, 0, 0))),
+		MinWeight:        l.minWeight,
+		MaxWeight:        l.maxWeight,
+		MinBias:          l.minBias,
+		MaxBias:          l.maxBias,
+	}
+}
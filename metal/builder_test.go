@@ -0,0 +1,184 @@
+package neuron
+
+import (
+	"strings"
+	"testing"
+
+	"tinybrain/initializer"
+)
+
+func TestBuilderBuild(t *testing.T) {
+	edges := EdgeList{{Pre: 0, Post: 0, Weight: 0.7}, {Pre: 3, Post: 1, Weight: -0.4}}
+	net, err := NewBuilder(4).Seed(2).TimeStep(0.5).
+		Layer("hidden", 6).Threshold(initializer.Uniform(1, 1.5)).BiasBounds(-0.5, 0.5).Done().
+		Layer("out", 2).Done().
+		Project(Input, "hidden", FixedInDegree{K: 3}, initializer.Normal(0, 0.3)).
+		Project("hidden", "hidden", AllToAll{}, initializer.Constant(0.1)).
+		Project("hidden", "out", edges, initializer.Initializer{}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(net.Layers) != 2 || net.TimeStep() != 0.5 || net.Seed != 2 {
+		t.Fatalf("%d layers, dt %v, seed %d", len(net.Layers), net.TimeStep(), net.Seed)
+	}
+	for j, n := range net.Layers[0].Neurons {
+		if len(n.Connections) != 3 || len(n.Recurrent) != 6 {
+			t.Errorf("hidden neuron %d: %d connections, %d recurrent", j, len(n.Connections), len(n.Recurrent))
+		}
+		if n.Threshold < 1 || n.Threshold >= 1.5 || n.MinBias != -0.5 || n.MaxBias != 0.5 {
+			t.Errorf("hidden neuron %d: threshold %v, bias bounds %v and %v", j, n.Threshold, n.MinBias, n.MaxBias)
+		}
+	}
+	out := net.Layers[1].Neurons
+	if out[0].Connections[0].Weight != 0.7 || out[1].Connections[0].Weight != -0.4 {
+		t.Errorf("edge weights %v and %v, want 0.7 and -0.4", out[0].Connections[0].Weight, out[1].Connections[0].Weight)
+	}
+	records, err := net.InitRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[int][]string{}
+	for _, r := range records {
+		fields[r.Layer] = append(fields[r.Layer], r.Field)
+	}
+	if got := strings.Join(fields[0], " "); got != "threshold decay bias refractoryPeriod weights recurrent" {
+		t.Errorf("hidden layer records %s", got)
+	}
+	if got := strings.Join(fields[1], " "); got != "threshold decay bias refractoryPeriod" {
+		t.Errorf("output layer records %s, want no weights for an edge list keeping its own", got)
+	}
+}
+
+func TestBuilderConvAndPool(t *testing.T) {
+	image := Shape{Channels: 1, Height: 6, Width: 6}
+	conv := Convolution{Input: image, Channels: 2, Kernel: 3, Stride: 1}
+	pool := Pooling{Input: Shape{Channels: 2, Height: 4, Width: 4}, Size: 2, Stride: 2}
+	net, err := NewBuilder(36).Seed(1).
+		Conv("conv", conv, initializer.HeUniform(1)).Threshold(initializer.Uniform(1, 2)).Done().
+		Pool("pool", pool).Done().
+		Layer("out", 3).Done().
+		Project("pool", "out", AllToAll{}, initializer.Constant(0.5)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []int{len(net.Layers[0].Neurons), len(net.Layers[1].Neurons), len(net.Layers[2].Neurons)}; got[0] != 32 || got[1] != 8 || got[2] != 3 {
+		t.Fatalf("layer sizes %v, want 32, 8 and 3", got)
+	}
+	first := net.Layers[0].Neurons[0]
+	for j, n := range net.Layers[0].Neurons {
+		if n.Threshold != first.Threshold {
+			t.Errorf("conv neuron %d: threshold %v, not the shared %v", j, n.Threshold, first.Threshold)
+		}
+	}
+
+	_, err = NewBuilder(36).
+		Conv("conv", conv, initializer.HeUniform(1)).Done().
+		Project(Input, "conv", AllToAll{}, initializer.Constant(1)).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "fixed connectivity") {
+		t.Errorf("projection onto a conv layer: got %v", err)
+	}
+	_, err = NewBuilder(30).Conv("conv", conv, initializer.HeUniform(1)).Done().Build()
+	if err == nil || !strings.Contains(err.Error(), "from 30") {
+		t.Errorf("conv over the wrong input width: got %v", err)
+	}
+}
+
+func TestBuilderRejects(t *testing.T) {
+	one := initializer.Constant(1)
+	for _, tc := range []struct {
+		name  string
+		build func() *Builder
+		want  string
+	}{
+		{"no layers", func() *Builder { return NewBuilder(2) }, "at least one layer"},
+		{"no inputs", func() *Builder {
+			return NewBuilder(0).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, one)
+		}, "inputs must be positive"},
+		{"unknown layer", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, one).Project("b", "a", AllToAll{}, one)
+		}, "unknown layer"},
+		{"unknown target", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, one).Project("a", "b", AllToAll{}, one)
+		}, "unknown layer"},
+		{"skipped layer", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Layer("b", 1).Done().
+				Project(Input, "a", AllToAll{}, one).Project(Input, "b", AllToAll{}, one)
+		}, "skips layers"},
+		{"backward projection", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Layer("b", 1).Done().
+				Project(Input, "a", AllToAll{}, one).Project("a", "b", AllToAll{}, one).Project("b", "a", AllToAll{}, one)
+		}, "skips layers"},
+		{"duplicate feedforward", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, one).Project(Input, "a", OneToOne{}, one)
+		}, "two feedforward projections"},
+		{"duplicate recurrent", func() *Builder {
+			return NewBuilder(2).Layer("a", 2).Done().Project(Input, "a", AllToAll{}, one).
+				Project("a", "a", AllToAll{}, one).Project("a", "a", OneToOne{}, one)
+		}, "two recurrent projections"},
+		{"no feedforward", func() *Builder { return NewBuilder(2).Layer("a", 1).Done() }, "no feedforward projection"},
+		{"duplicate layer", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Layer("a", 1).Done().Project(Input, "a", AllToAll{}, one)
+		}, "duplicate layer"},
+		{"reserved name", func() *Builder {
+			return NewBuilder(2).Layer(Input, 1).Done()
+		}, "reserved"},
+		{"empty layer", func() *Builder {
+			return NewBuilder(2).Layer("a", 0).Done().Project(Input, "a", AllToAll{}, one)
+		}, "at least one neuron"},
+		{"nil pattern", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Project(Input, "a", nil, one)
+		}, "needs a pattern"},
+		{"nil weight distribution", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, initializer.Initializer{})
+		}, "needs a weight initializer"},
+		{"invalid weight distribution", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, initializer.Uniform(1, 0))
+		}, "above max"},
+		{"nil parameter distribution", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Threshold(initializer.Initializer{}).Done().Project(Input, "a", AllToAll{}, one)
+		}, "threshold"},
+		{"scaled parameter distribution", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).Decay(initializer.HeNormal(1)).Done().Project(Input, "a", AllToAll{}, one)
+		}, "only applies to weights"},
+		{"min weight above max", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).WeightBounds(1, -1).Done().Project(Input, "a", AllToAll{}, one)
+		}, "min weight 1 is above max weight -1"},
+		{"min bias above max", func() *Builder {
+			return NewBuilder(2).Layer("a", 1).BiasBounds(0.5, 0).Done().Project(Input, "a", AllToAll{}, one)
+		}, "min bias 0.5 is above max bias 0"},
+		{"bad timestep", func() *Builder {
+			return NewBuilder(2).TimeStep(-1).Layer("a", 1).Done().Project(Input, "a", AllToAll{}, one)
+		}, "timestep"},
+		{"bad pattern", func() *Builder {
+			return NewBuilder(2).Layer("a", 3).Done().Project(Input, "a", FixedInDegree{K: 5}, one)
+		}, "input->a"},
+	} {
+		net, err := tc.build().Build()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tc.name, err, tc.want)
+		}
+		if net != nil {
+			t.Errorf("%s: returned a network with the error", tc.name)
+		}
+	}
+}
+
+func TestBuilderReportsEveryProblem(t *testing.T) {
+	_, err := NewBuilder(2).
+		Layer("a", 1).WeightBounds(1, 0).Done().
+		Layer("b", 1).Threshold(initializer.Initializer{}).Done().
+		Project(Input, "a", AllToAll{}, initializer.Constant(1)).
+		Project(Input, "b", AllToAll{}, initializer.Constant(1)).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"min weight", "skips layers", "threshold", `"b" has no feedforward`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package neuron
+
+import "fmt"
+
+// Compartment is a dendritic compartment of a neuron. It integrates the
+// connections that target it with its own decay and exchanges current with
+// the soma through the coupling conductance. When its potential reaches
+// PlateauThreshold it fires a dendritic plateau, holding PlateauPotential for
+// PlateauDuration milliseconds, long enough to drive the soma on its own, and
+// then returning to rest.
+// Decay and Coupling are per millisecond.
+type Compartment struct {
+	Decay            float64 `json:"decay"`
+	Coupling         float64 `json:"coupling"`                   // fraction of the potential difference to the soma exchanged per ms
+	PlateauThreshold float64 `json:"plateauThreshold,omitempty"` // 0 disables plateaus
+	PlateauPotential float64 `json:"plateauPotential,omitempty"`
+	PlateauDuration  int     `json:"plateauDuration,omitempty"`
+
+	Potential    float64 `json:"potential"`
+	PlateauTimer int     `json:"plateauTimer,omitempty"` // steps left in the current plateau
+}
+
+// NewCompartment returns a passive dendrite
+func NewCompartment(decay, coupling float64) Compartment {
+	return Compartment{Decay: decay, Coupling: coupling}
+}
+
+// Validate checks that the compartment is stable
+func (c Compartment) Validate() error {
+	if c.Decay < 0 || c.Decay > 1 {
+		return fmt.Errorf("compartment: decay must be in [0, 1], got %v", c.Decay)
+	}
+	if c.Coupling < 0 || c.Coupling > 1 {
+		return fmt.Errorf("compartment: coupling must be in [0, 1], got %v", c.Coupling)
+	}
+	if c.PlateauThreshold < 0 || c.PlateauDuration < 0 {
+		return fmt.Errorf("compartment: plateau threshold and duration must not be negative")
+	}
+	return nil
+}
+
+// Plateau reports whether the compartment is in a plateau
+func (c *Compartment) Plateau() bool {
+	return c.PlateauTimer > 0
+}
+
+// step integrates the drive over a step of dt milliseconds, fires or holds a
+// plateau and returns the current that flows into a soma at potential soma
+func (c *Compartment) step(drive, soma, dt float64) float64 {
+	c.Potential = c.Potential*kept(c.Decay, dt) + drive
+	if c.PlateauTimer == 0 && c.PlateauThreshold > 0 && c.Potential >= c.PlateauThreshold {
+		c.PlateauTimer = steps(float64(c.PlateauDuration), dt)
+	}
+	ending := false
+	if c.PlateauTimer > 0 {
+		c.PlateauTimer--
+		c.Potential = max(c.Potential, c.PlateauPotential)
+		ending = c.PlateauTimer == 0
+	}
+	current := chance(c.Coupling, dt) * (c.Potential - soma)
+	c.Potential -= current
+	if ending {
+		// The plateau ends by repolarising, so it cannot trigger itself again
+		c.Potential = 0
+	}
+	return current
+}
+
+// SetDendrites gives every neuron of the layer its own copies of dendrites
+// and routes each input connection to the compartment returned by target:
+// 0 for the soma, k for dendrites[k-1]
+func (l *Layer) SetDendrites(dendrites []Compartment, target func(input int) int) error {
+	for _, d := range dendrites {
+		if err := d.Validate(); err != nil {
+			return err
+		}
+	}
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+		n.Dendrites = append([]Compartment(nil), dendrites...)
+		for i := range n.Connections {
+			input := n.InputIndex(i)
+			k := target(input)
+			if k < 0 || k > len(dendrites) {
+				return fmt.Errorf("compartment: input %d targets compartment %d of %d", input, k, len(dendrites))
+			}
+			n.Connections[i].Target = k
+		}
+	}
+	return nil
+}
+
+// addDrive adds a synaptic drive to the dendrite a connection targets
+func addDrive(dendritic []float64, target int, drive float64) {
+	dendritic[target-1] += drive
+}
+
+// dendrites advances every dendrite by a step of dt milliseconds with its
+// share of the drive and returns the total current into the soma
+func (n *SpikingNeuron) dendrites(drive []float64, dt float64) float64 {
+	current := 0.0
+	for d := range n.Dendrites {
+		current += n.Dendrites[d].step(drive[d], n.MembranePotential, dt)
+	}
+	return current
+}
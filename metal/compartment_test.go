@@ -0,0 +1,128 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSetDendritesSparse(t *testing.T) {
+	layer := NewLayer([]SpikingNeuron{*NewSpikingNeuron(4, 1, 0.9, 0, 0)})
+	edges := EdgeList{{Pre: 2, Post: 0, Weight: 1}, {Pre: 3, Post: 0, Weight: 1}}
+	if err := layer.Wire(4, edges, edges.Weights(), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Inputs 2 and 3 go to the dendrite; the targets follow the inputs, not
+	// the positions of the connections
+	err := layer.SetDendrites([]Compartment{NewCompartment(0.9, 0.1)}, func(input int) int {
+		if input >= 2 {
+			return 1
+		}
+		return 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range layer.Neurons[0].Connections {
+		if c.Target != 1 {
+			t.Errorf("connection %d from input %d targets %d, want the dendrite", i, layer.Neurons[0].InputIndex(i), c.Target)
+		}
+	}
+
+	err = layer.SetDendrites([]Compartment{NewCompartment(0.9, 0.1)}, func(input int) int { return input })
+	if err == nil {
+		t.Error("input 2 targeting a missing compartment: expected an error")
+	}
+}
+
+func TestCompartmentCoupling(t *testing.T) {
+	c := NewCompartment(1, 0.25)
+	if current := c.step(1, 0, 1); current != 0.25 || c.Potential != 0.75 {
+		t.Errorf("current %v leaving potential %v, want a quarter of the difference to flow", current, c.Potential)
+	}
+	// Current flows back when the soma is above the dendrite
+	if current := c.step(0, 2, 1); current >= 0 {
+		t.Errorf("current %v from a dendrite below the soma, want it negative", current)
+	}
+	// Over two half steps the same fraction is exchanged as over one step
+	half, whole := NewCompartment(1, 0.25), NewCompartment(1, 0.25)
+	half.Potential, whole.Potential = 1, 1
+	half.step(0, 0, 0.5)
+	half.step(0, 0, 0.5)
+	whole.step(0, 0, 1)
+	if math.Abs(half.Potential-whole.Potential) > 1e-12 {
+		t.Errorf("potential %v after two half steps, %v after one step", half.Potential, whole.Potential)
+	}
+
+	isolated := NewCompartment(0.9, 0)
+	if current := isolated.step(1, 0, 1); current != 0 {
+		t.Errorf("uncoupled dendrite sent %v to the soma", current)
+	}
+}
+
+func TestDendriticPlateau(t *testing.T) {
+	for _, dt := range []float64{1, 0.5} {
+		c := Compartment{Decay: 0.5, Coupling: 0.1, PlateauThreshold: 0.5, PlateauPotential: 1.5, PlateauDuration: 5}
+		steps := steps(5, dt)
+		var currents []float64
+		for step := 0; step < steps+3; step++ {
+			drive := 0.0
+			if step == 0 {
+				drive = 0.6
+			}
+			currents = append(currents, c.step(drive, 0, dt))
+		}
+		held := chance(0.1, dt) * 1.5
+		for step, current := range currents {
+			if step < steps && math.Abs(current-held) > 1e-12 {
+				t.Errorf("dt %v step %d: current %v during the plateau, want %v", dt, step, current, held)
+			}
+			if step >= steps && current >= held/2 {
+				t.Errorf("dt %v step %d: current %v after the plateau, want it to fade", dt, step, current)
+			}
+		}
+		if c.Plateau() {
+			t.Errorf("dt %v: plateau still active after %d steps", dt, steps+3)
+		}
+	}
+
+	// Below threshold the dendrite stays passive
+	c := Compartment{Decay: 0.5, Coupling: 0.1, PlateauThreshold: 0.5, PlateauPotential: 1.5, PlateauDuration: 5}
+	c.step(0.4, 0, 1)
+	if c.Plateau() {
+		t.Error("a drive below the plateau threshold started a plateau")
+	}
+}
+
+func TestPlateauDrivesSoma(t *testing.T) {
+	// One input of 0.6 cannot fire the soma alone, but it starts a plateau
+	// that keeps driving the soma until it fires
+	spikes := func(target int) int {
+		n := NewSpikingNeuron(1, 1, 0.9, 0, 0)
+		n.Connections[0].Weight = 0.6
+		layer := NewLayer([]SpikingNeuron{*n})
+		layer.SetNoise(Noise{Kind: NoNoise})
+		plateau := Compartment{Decay: 0.5, Coupling: 0.2, PlateauThreshold: 0.5, PlateauPotential: 2, PlateauDuration: 10}
+		if err := layer.SetDendrites([]Compartment{plateau}, func(int) int { return target }); err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for step := 0; step < 12; step++ {
+			input := 0.0
+			if step == 0 {
+				input = 1
+			}
+			out, err := layer.Forward([]float64{input}, step, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			count += out[0]
+		}
+		return count
+	}
+	if n := spikes(0); n != 0 {
+		t.Errorf("input onto the soma fired %d spikes, want none", n)
+	}
+	if n := spikes(1); n == 0 {
+		t.Error("input onto the dendrite started no plateau strong enough to fire the soma")
+	}
+}
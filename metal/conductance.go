@@ -0,0 +1,99 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+)
+
+// Kernel is the time course of a synaptic conductance after an input
+type Kernel string
+
+const (
+	// Exponential conductances jump on input and decay with the time constant
+	Exponential Kernel = "exponential"
+	// Alpha conductances rise and fall again, peaking one time constant
+	// after the input at the height an exponential kernel would jump to
+	Alpha Kernel = "alpha"
+)
+
+// Conductance turns a neuron's synapses from current-based into
+// conductance-based. Positive drives (input times weight) open excitatory
+// conductance and negative drives inhibitory conductance; each pulls the
+// membrane potential towards its reversal potential, in proportion to how far
+// away it is. An inhibitory reversal at rest (0) gives pure shunting
+// inhibition: it has no effect on a resting neuron but divides the effect of
+// excitation. Time constants are in milliseconds and conductances are per
+// millisecond.
+type Conductance struct {
+	Kernel    Kernel  `json:"kernel"`
+	TauE      float64 `json:"tauE"`      // decay of the excitatory conductance
+	TauI      float64 `json:"tauI"`      // decay of the inhibitory conductance
+	ReversalE float64 `json:"reversalE"` // excitatory reversal potential, above threshold
+	ReversalI float64 `json:"reversalI"` // inhibitory reversal potential, at or below rest
+
+	GE float64 `json:"gE"` // excitatory conductance
+	GI float64 `json:"gI"` // inhibitory conductance
+	XE float64 `json:"xE,omitempty"`
+	XI float64 `json:"xI,omitempty"` // rising phase of alpha kernels
+}
+
+// NewConductance returns fast excitatory and slower inhibitory conductances
+// with reversal potentials suited to thresholds around 1
+func NewConductance(kernel Kernel) *Conductance {
+	return &Conductance{Kernel: kernel, TauE: 5, TauI: 10, ReversalE: 4, ReversalI: -0.5}
+}
+
+// Validate checks the kernel, time constants and reversal potentials
+func (c *Conductance) Validate() error {
+	switch c.Kernel {
+	case Exponential, Alpha:
+	default:
+		return fmt.Errorf("conductance: unknown kernel %q", c.Kernel)
+	}
+	if c.TauE <= 0 || c.TauI <= 0 {
+		return fmt.Errorf("conductance: time constants must be positive")
+	}
+	if c.ReversalE <= c.ReversalI {
+		return fmt.Errorf("conductance: excitatory reversal %v must be above inhibitory reversal %v", c.ReversalE, c.ReversalI)
+	}
+	return nil
+}
+
+// update advances the conductances by a step of dt milliseconds and adds the
+// new drives
+func (c *Conductance) update(excitation, inhibition, dt float64) {
+	c.GE, c.XE = c.kernel(c.GE, c.XE, c.TauE, excitation, dt)
+	c.GI, c.XI = c.kernel(c.GI, c.XI, c.TauI, inhibition, dt)
+}
+
+func (c *Conductance) kernel(g, x, tau, input, dt float64) (float64, float64) {
+	d := math.Exp(-dt / tau)
+	if c.Kernel == Alpha {
+		x *= d
+		g = g*d + math.E/tau*x*dt
+		return g, x + input
+	}
+	return g*d + input, 0
+}
+
+// drive relaxes v for a step of dt milliseconds towards the potential at which the
+// excitatory and inhibitory currents cancel. The relaxation is exact for
+// conductances held over the step, so large conductances cannot overshoot.
+func (c *Conductance) drive(v, dt float64) float64 {
+	g := c.GE + c.GI
+	if g <= 0 {
+		return v
+	}
+	target := (c.GE*c.ReversalE + c.GI*c.ReversalI) / g
+	return target + (v-target)*math.Exp(-g*dt)
+}
+
+// SetConductance gives every neuron of the layer its own copy of c, with
+// closed channels
+func (l *Layer) SetConductance(c Conductance) {
+	for i := range l.Neurons {
+		own := c
+		own.GE, own.GI, own.XE, own.XI = 0, 0, 0, 0
+		l.Neurons[i].Conductance = &own
+	}
+}
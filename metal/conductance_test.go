@@ -0,0 +1,56 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+// settle drives a silent neuron (threshold far away) with constant excitation
+// and inhibition through two inputs and returns its final potential
+func settle(c *Conductance, excitation, inhibition float64) float64 {
+	n := NewSpikingNeuron(2, 100, 0.9, 0, 0)
+	n.MinWeight = -1
+	n.Connections[0].Weight, n.Connections[1].Weight = excitation, -inhibition
+	layer := NewLayer([]SpikingNeuron{*n})
+	layer.SetNoise(Noise{Kind: NoNoise})
+	if c != nil {
+		layer.SetConductance(*c)
+	}
+	for step := 0; step < 100; step++ {
+		layer.Forward([]float64{1, 1}, step, 0)
+	}
+	return layer.Neurons[0].MembranePotential
+}
+
+func TestShuntingInhibition(t *testing.T) {
+	// Inhibition reverses at rest: pure shunting
+	shunt := &Conductance{Kernel: Exponential, TauE: 5, TauI: 5, ReversalE: 4, ReversalI: 0}
+	const inhibition = 0.05
+
+	if v := settle(shunt, 0, inhibition); v != 0 {
+		t.Errorf("shunting inhibition alone moved a resting neuron to %v", v)
+	}
+	if v := settle(nil, 0, inhibition); v >= 0 {
+		t.Errorf("current-based inhibition alone left the neuron at %v, want it hyperpolarised", v)
+	}
+
+	// Shunting divides the response to excitation by about the same factor
+	// at every strength; current-based inhibition subtracts the same amount
+	var ratios, differences []float64
+	for _, e := range []float64{0.002, 0.004, 0.008} {
+		alone, inhibited := settle(shunt, e, 0), settle(shunt, e, inhibition)
+		if inhibited <= 0 || inhibited >= alone {
+			t.Fatalf("excitation %v: potential %v with shunting, %v without; want it reduced but positive", e, inhibited, alone)
+		}
+		ratios = append(ratios, inhibited/alone)
+		differences = append(differences, settle(nil, e, 0)-settle(nil, e, inhibition))
+	}
+	for i := 1; i < len(ratios); i++ {
+		if math.Abs(ratios[i]-ratios[0]) > 0.2*ratios[0] {
+			t.Errorf("shunting scaled excitation by %v, want about the same factor at every strength", ratios)
+		}
+		if math.Abs(differences[i]-differences[0]) > 1e-9 {
+			t.Errorf("current-based inhibition removed %v, want the same amount at every strength", differences)
+		}
+	}
+}
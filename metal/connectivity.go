@@ -0,0 +1,215 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// Connectivity decides which inputs each neuron of a layer reads. Sources
+// returns, for each of post neurons, the indices of the pre inputs it connects
+// to, drawing from rng when the pattern is random.
+type Connectivity interface {
+	Sources(pre, post int, rng *rand.Rand) ([][]int, error)
+}
+
+// AllToAll connects every neuron to every input, as dense layers do
+type AllToAll struct{}
+
+func (AllToAll) Sources(pre, post int, rng *rand.Rand) ([][]int, error) {
+	sources := make([][]int, post)
+	for j := range sources {
+		sources[j] = make([]int, pre)
+		for i := range sources[j] {
+			sources[j][i] = i
+		}
+	}
+	return sources, nil
+}
+
+// FixedProbability connects each neuron to each input independently with
+// probability P
+type FixedProbability struct {
+	P float64
+}
+
+func (c FixedProbability) Sources(pre, post int, rng *rand.Rand) ([][]int, error) {
+	if c.P < 0 || c.P > 1 {
+		return nil, fmt.Errorf("connectivity: probability must be in [0, 1], got %v", c.P)
+	}
+	sources := make([][]int, post)
+	for j := range sources {
+		for i := 0; i < pre; i++ {
+			if uniform(rng) < c.P {
+				sources[j] = append(sources[j], i)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// FixedInDegree connects each neuron to K distinct inputs chosen at random
+type FixedInDegree struct {
+	K int
+}
+
+func (c FixedInDegree) Sources(pre, post int, rng *rand.Rand) ([][]int, error) {
+	if c.K < 0 || c.K > pre {
+		return nil, fmt.Errorf("connectivity: in-degree %d out of range for %d inputs", c.K, pre)
+	}
+	sources := make([][]int, post)
+	for j := range sources {
+		// Partial Fisher–Yates shuffle of the input indices
+		perm := make([]int, pre)
+		for i := range perm {
+			perm[i] = i
+		}
+		for i := 0; i < c.K; i++ {
+			k := i + int(uniform(rng)*float64(pre-i))
+			perm[i], perm[k] = perm[k], perm[i]
+		}
+		sources[j] = slices.Clone(perm[:c.K])
+		slices.Sort(sources[j])
+	}
+	return sources, nil
+}
+
+// OneToOne connects neuron i to input i; both sides must have the same size
+type OneToOne struct{}
+
+func (OneToOne) Sources(pre, post int, rng *rand.Rand) ([][]int, error) {
+	if pre != post {
+		return nil, fmt.Errorf("connectivity: one-to-one needs as many neurons as inputs, got %d and %d", post, pre)
+	}
+	sources := make([][]int, post)
+	for j := range sources {
+		sources[j] = []int{j}
+	}
+	return sources, nil
+}
+
+// Grid places neurons on a Width×Height grid in row-major order. A Height of
+// 0 or 1 is a line.
+type Grid struct {
+	Width  int `json:"width"`
+	Height int `json:"height,omitempty"`
+}
+
+// size is the number of neurons on the grid
+func (g Grid) size() int {
+	return g.Width * max(g.Height, 1)
+}
+
+// position returns the coordinates of neuron i, scaled to the unit square so
+// that grids of different sizes overlay each other
+func (g Grid) position(i int) (float64, float64) {
+	x := (float64(i%g.Width) + 0.5) / float64(g.Width)
+	h := max(g.Height, 1)
+	y := (float64(i/g.Width) + 0.5) / float64(h)
+	return x, y
+}
+
+// DistanceDependent connects neurons to inputs with a probability that falls
+// off as a Gaussian of their distance: P·exp(-d²/2σ²). Both sides are laid
+// out on grids scaled to the unit square, so Sigma is a fraction of the
+// grid's extent.
+type DistanceDependent struct {
+	Pre, Post Grid
+	P         float64 // probability at distance zero
+	Sigma     float64
+}
+
+func (c DistanceDependent) Sources(pre, post int, rng *rand.Rand) ([][]int, error) {
+	if c.Pre.size() != pre || c.Post.size() != post {
+		return nil, fmt.Errorf("connectivity: grids of %d and %d neurons for %d inputs and %d neurons", c.Pre.size(), c.Post.size(), pre, post)
+	}
+	if c.Sigma <= 0 || c.P < 0 || c.P > 1 {
+		return nil, fmt.Errorf("connectivity: need a positive sigma and a probability in [0, 1]")
+	}
+	sources := make([][]int, post)
+	for j := range sources {
+		px, py := c.Post.position(j)
+		for i := 0; i < pre; i++ {
+			x, y := c.Pre.position(i)
+			d2 := (x-px)*(x-px) + (y-py)*(y-py)
+			if uniform(rng) < c.P*math.Exp(-d2/(2*c.Sigma*c.Sigma)) {
+				sources[j] = append(sources[j], i)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// Edge is one connection of an EdgeList
+type Edge struct {
+	Pre    int     `json:"pre"`
+	Post   int     `json:"post"`
+	Weight float64 `json:"weight"`
+}
+
+// EdgeList connects exactly the listed pairs
+type EdgeList []Edge
+
+func (e EdgeList) Sources(pre, post int, rng *rand.Rand) ([][]int, error) {
+	sources := make([][]int, post)
+	seen := map[[2]int]bool{}
+	for _, edge := range e {
+		if edge.Pre < 0 || edge.Pre >= pre || edge.Post < 0 || edge.Post >= post {
+			return nil, fmt.Errorf("connectivity: edge %d->%d out of range for %d inputs and %d neurons", edge.Pre, edge.Post, pre, post)
+		}
+		if seen[[2]int{edge.Pre, edge.Post}] {
+			return nil, fmt.Errorf("connectivity: duplicate edge %d->%d", edge.Pre, edge.Post)
+		}
+		seen[[2]int{edge.Pre, edge.Post}] = true
+		sources[edge.Post] = append(sources[edge.Post], edge.Pre)
+	}
+	for j := range sources {
+		slices.Sort(sources[j])
+	}
+	return sources, nil
+}
+
+// Weights returns the weights of the listed edges, for use with Wire
+func (e EdgeList) Weights() func(pre, post int) float64 {
+	weights := make(map[[2]int]float64, len(e))
+	for _, edge := range e {
+		weights[[2]int{edge.Pre, edge.Post}] = edge.Weight
+	}
+	return func(pre, post int) float64 {
+		return weights[[2]int{pre, post}]
+	}
+}
+
+// Wire replaces the input connections of every neuron of the layer by those
+// of the pattern, over an input of the given width, with weights from weight.
+// All-to-all wiring keeps neurons dense; every other pattern makes them
+// sparse. Random patterns draw from rng, or the global source when nil.
+func (l *Layer) Wire(inputs int, pattern Connectivity, weight func(pre, post int) float64, rng *rand.Rand) error {
+	sources, err := pattern.Sources(inputs, len(l.Neurons), rng)
+	if err != nil {
+		return err
+	}
+	_, dense := pattern.(AllToAll)
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+		n.Sparse = !dense
+		n.Connections = make([]Connection, len(sources[j]))
+		for k, i := range sources[j] {
+			n.Connections[k] = Connection{Weight: weight(i, j), LastPreSpike: -100}
+			if !dense {
+				n.Connections[k].Source = i
+			}
+		}
+	}
+	l.Inputs = inputs
+	return nil
+}
+
+// uniform draws from rng, or from the global source when it is nil
+func uniform(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.Float64()
+	}
+	return rng.Float64()
+}
@@ -0,0 +1,137 @@
+package neuron
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// checkSources verifies that every neuron reads distinct inputs in range, in
+// increasing order, and returns the number of connections
+func checkSources(t *testing.T, name string, sources [][]int, pre, post int) int {
+	t.Helper()
+	if len(sources) != post {
+		t.Fatalf("%s: sources for %d neurons, want %d", name, len(sources), post)
+	}
+	total := 0
+	for j, s := range sources {
+		for k, i := range s {
+			if i < 0 || i >= pre || k > 0 && i <= s[k-1] {
+				t.Fatalf("%s: neuron %d reads %v, want distinct inputs in [0, %d) in order", name, j, s, pre)
+			}
+		}
+		total += len(s)
+	}
+	return total
+}
+
+func TestAllToAll(t *testing.T) {
+	sources, err := AllToAll{}.Sources(7, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := checkSources(t, "all-to-all", sources, 7, 3); total != 21 {
+		t.Errorf("%d connections, want 21", total)
+	}
+}
+
+func TestFixedInDegree(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	sources, err := FixedInDegree{K: 5}.Sources(40, 200, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSources(t, "in-degree", sources, 40, 200)
+	counts := make([]int, 40)
+	for j, s := range sources {
+		if len(s) != 5 {
+			t.Fatalf("neuron %d has in-degree %d, want 5", j, len(s))
+		}
+		for _, i := range s {
+			counts[i]++
+		}
+	}
+	// Every input is chosen 200·5/40 = 25 times on average
+	for i, c := range counts {
+		if c < 8 || c > 45 {
+			t.Errorf("input %d chosen %d times, want about 25", i, c)
+		}
+	}
+
+	if s, _ := (FixedInDegree{K: 40}).Sources(40, 2, rng); len(s[0]) != 40 {
+		t.Errorf("in-degree equal to the input width: %d connections, want all 40", len(s[0]))
+	}
+	for _, k := range []int{-1, 41} {
+		if _, err := (FixedInDegree{K: k}).Sources(40, 2, rng); err == nil {
+			t.Errorf("in-degree %d for 40 inputs: expected an error", k)
+		}
+	}
+}
+
+func TestFixedProbability(t *testing.T) {
+	rng := rand.New(rand.NewPCG(2, 2))
+	for _, p := range []float64{0, 0.1, 0.5, 1} {
+		sources, err := FixedProbability{P: p}.Sources(200, 200, rng)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := checkSources(t, "probability", sources, 200, 200)
+		// The density has a standard deviation below 0.003 for 40000 pairs
+		if density := float64(total) / 40000; math.Abs(density-p) > 0.01 {
+			t.Errorf("p = %v: density %v", p, density)
+		}
+	}
+	for _, p := range []float64{-0.1, 1.1} {
+		if _, err := (FixedProbability{P: p}).Sources(4, 4, rng); err == nil {
+			t.Errorf("p = %v: expected an error", p)
+		}
+	}
+}
+
+func TestWireSparseness(t *testing.T) {
+	layer := NewLayer(make([]SpikingNeuron, 3))
+	weight := func(pre, post int) float64 { return 0.1 * float64(pre+1) }
+	if err := layer.Wire(6, FixedInDegree{K: 2}, weight, rand.New(rand.NewPCG(3, 3))); err != nil {
+		t.Fatal(err)
+	}
+	for j, n := range layer.Neurons {
+		if !n.Sparse || len(n.Connections) != 2 {
+			t.Fatalf("neuron %d: sparse %v with %d connections, want sparse with 2", j, n.Sparse, len(n.Connections))
+		}
+		for i, c := range n.Connections {
+			if want := 0.1 * float64(c.Source+1); c.Weight != want {
+				t.Errorf("neuron %d connection %d from input %d: weight %v, want %v", j, i, c.Source, c.Weight, want)
+			}
+		}
+	}
+	if err := layer.Wire(6, AllToAll{}, weight, nil); err != nil {
+		t.Fatal(err)
+	}
+	if layer.Neurons[0].Sparse || len(layer.Neurons[0].Connections) != 6 {
+		t.Error("all-to-all wiring should leave the neurons dense")
+	}
+}
+
+func TestSparseStepDoesNotAllocate(t *testing.T) {
+	// Large enough that the buffers could not live on the stack
+	n := NewSpikingNeuron(0, 1000, 0.9, 0, 0)
+	n.Sparse = true
+	for i := 0; i < 64; i++ {
+		n.Connections = append(n.Connections, Connection{Weight: 0.1, Source: 2 * i, Target: i % 9})
+	}
+	for d := 0; d < 8; d++ {
+		n.Dendrites = append(n.Dendrites, NewCompartment(0.9, 0.1))
+	}
+	inputs := make([]float64, 128)
+	for i := range inputs {
+		inputs[i] = float64(i % 2)
+	}
+	step := 0
+	allocs := testing.AllocsPerRun(100, func() {
+		n.forward(inputs, nil, step, 0, 1, 0, 0, nil)
+		step++
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per step, want none", allocs)
+	}
+}
@@ -0,0 +1,180 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+)
+
+// ConstraintKind selects how a Constraint acts on the input weights of a
+// neuron
+type ConstraintKind string
+
+const (
+	// L1Norm rescales the weights so that the sum of their magnitudes is Target
+	L1Norm ConstraintKind = "l1"
+	// L2Norm rescales the weights so that their Euclidean norm is Target
+	L2Norm ConstraintKind = "l2"
+	// Budget shifts every weight by the same amount so that they sum to
+	// Target: one synapse can only grow at the expense of the others
+	Budget ConstraintKind = "budget"
+	// SoftBounds scales each weight change by the distance to the bound it
+	// moves towards, so weights approach MinWeight and MaxWeight gradually
+	SoftBounds ConstraintKind = "softBounds"
+)
+
+// Constraint keeps learning competitive by limiting the input weights of each
+// neuron after every update. Constraints act on the feed-forward connections
+// only and always leave the weights inside the neuron's bounds.
+type Constraint struct {
+	Kind   ConstraintKind `json:"kind"`
+	Target float64        `json:"target,omitempty"` // norm for l1 and l2, total for budget
+}
+
+// Validate checks the kind and target of the constraint
+func (c Constraint) Validate() error {
+	switch c.Kind {
+	case L1Norm, L2Norm:
+		if c.Target <= 0 {
+			return fmt.Errorf("constraint %s: target must be positive, got %v", c.Kind, c.Target)
+		}
+	case Budget, SoftBounds:
+	default:
+		return fmt.Errorf("constraint: unknown kind %q", c.Kind)
+	}
+	return nil
+}
+
+// apply enforces the constraint on one neuron. old holds the weights before
+// the update and is needed by soft bounds only; nil skips them.
+func (c Constraint) apply(n *SpikingNeuron, old []float64) {
+	switch c.Kind {
+	case L1Norm:
+		norm := 0.0
+		for _, conn := range n.Connections {
+			norm += math.Abs(conn.Weight)
+		}
+		n.scaleWeights(c.Target, norm)
+	case L2Norm:
+		norm := 0.0
+		for _, conn := range n.Connections {
+			norm += conn.Weight * conn.Weight
+		}
+		n.scaleWeights(c.Target, math.Sqrt(norm))
+	case Budget:
+		n.shiftWeights(c.Target)
+	case SoftBounds:
+		if old == nil {
+			return
+		}
+		for i := range n.Connections {
+			lo, hi := n.WeightBounds(n.Connections[i])
+			if hi <= lo {
+				continue
+			}
+			w := old[i]
+			delta := n.Connections[i].Weight - w
+			if delta > 0 {
+				w += delta * (hi - w) / (hi - lo)
+			} else {
+				w += delta * (w - lo) / (hi - lo)
+			}
+			n.Connections[i].Weight = clamp(w, lo, hi)
+		}
+	}
+}
+
+// scaleWeights multiplies the weights by target/norm
+func (n *SpikingNeuron) scaleWeights(target, norm float64) {
+	if norm == 0 {
+		return
+	}
+	for i := range n.Connections {
+		n.Connections[i].Weight *= target / norm
+		n.clampWeight(&n.Connections[i])
+	}
+}
+
+// shiftWeights moves the weights towards summing to total, spreading the
+// difference evenly over the weights that have not reached a bound
+func (n *SpikingNeuron) shiftWeights(total float64) {
+	for range n.Connections {
+		sum := 0.0
+		for _, conn := range n.Connections {
+			sum += conn.Weight
+		}
+		residual := total - sum
+		if math.Abs(residual) < 1e-12 {
+			return
+		}
+		movable := func(c Connection) bool {
+			lo, hi := n.WeightBounds(c)
+			return residual > 0 && c.Weight < hi || residual < 0 && c.Weight > lo
+		}
+		free := 0
+		for _, conn := range n.Connections {
+			if movable(conn) {
+				free++
+			}
+		}
+		if free == 0 {
+			return
+		}
+		for i := range n.Connections {
+			if movable(n.Connections[i]) {
+				n.Connections[i].Weight += residual / float64(free)
+				n.clampWeight(&n.Connections[i])
+			}
+		}
+	}
+}
+
+// Weights returns a copy of the input weights of every neuron, as needed by
+// Constrain to apply soft bounds
+func (l *Layer) Weights() [][]float64 {
+	weights := make([][]float64, len(l.Neurons))
+	for j := range l.Neurons {
+		weights[j] = l.Neurons[j].weights()
+	}
+	return weights
+}
+
+func (n *SpikingNeuron) weights() []float64 {
+	w := make([]float64, len(n.Connections))
+	for i, conn := range n.Connections {
+		w[i] = conn.Weight
+	}
+	return w
+}
+
+// Constrain applies the layer's constraints, in order, to every neuron. old
+// holds the weights before the update, as returned by Weights; with nil, soft
+// bounds are skipped. Forward calls it after every step, and the trainers
+// after every optimizer step. Convolutional layers then share the updated
+// weights again. Changes made there reach the network's OnWeightUpdate hooks;
+// a direct call does not, as the layer does not know its network.
+func (l *Layer) Constrain(old [][]float64) {
+	for j := range l.Neurons {
+		var w []float64
+		if old != nil {
+			w = old[j]
+		}
+		l.constrain(&l.Neurons[j], w)
+	}
+	l.tie()
+}
+
+func (l *Layer) constrain(n *SpikingNeuron, old []float64) {
+	for _, c := range l.Constraints {
+		c.apply(n, old)
+	}
+}
+
+// softBounds reports whether any constraint needs the weights before the update
+func (l *Layer) softBounds() bool {
+	for _, c := range l.Constraints {
+		if c.Kind == SoftBounds {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package neuron
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// constrained returns a layer of neurons with random weights in [-1, 1) and
+// the given constraints
+func constrained(rng *rand.Rand, constraints ...Constraint) *Layer {
+	neurons := make([]SpikingNeuron, 4)
+	for j := range neurons {
+		n := NewSpikingNeuron(6, 1, 0.9, 0, 0)
+		n.MinWeight, n.MaxWeight = -1, 1
+		for i := range n.Connections {
+			n.Connections[i].Weight = rng.Float64()*2 - 1
+		}
+		neurons[j] = *n
+	}
+	layer := NewLayer(neurons)
+	layer.Constraints = constraints
+	return layer
+}
+
+func norms(n SpikingNeuron) (l1, l2, sum float64) {
+	for _, c := range n.Connections {
+		l1 += math.Abs(c.Weight)
+		l2 += c.Weight * c.Weight
+		sum += c.Weight
+	}
+	return l1, math.Sqrt(l2), sum
+}
+
+func TestNormConstraints(t *testing.T) {
+	rng := rand.New(rand.NewSource(9))
+	for _, c := range []Constraint{{Kind: L1Norm, Target: 1.5}, {Kind: L2Norm, Target: 0.8}} {
+		layer := constrained(rng, c)
+		before := layer.Weights()
+		layer.Constrain(nil)
+		for j, n := range layer.Neurons {
+			l1, l2, _ := norms(n)
+			got := map[ConstraintKind]float64{L1Norm: l1, L2Norm: l2}[c.Kind]
+			if math.Abs(got-c.Target) > 1e-9 {
+				t.Errorf("%s: neuron %d has norm %v, want %v", c.Kind, j, got, c.Target)
+			}
+			// Rescaling keeps the direction of the weight vector
+			ratio := n.Connections[0].Weight / before[j][0]
+			for i, conn := range n.Connections {
+				if math.Abs(conn.Weight-ratio*before[j][i]) > 1e-9 {
+					t.Errorf("%s: neuron %d weight %d was not scaled like the others", c.Kind, j, i)
+				}
+			}
+		}
+	}
+}
+
+func TestBudgetConstraint(t *testing.T) {
+	layer := constrained(rand.New(rand.NewSource(10)), Constraint{Kind: Budget, Target: 2})
+	layer.Constrain(nil)
+	for j, n := range layer.Neurons {
+		if _, _, sum := norms(n); math.Abs(sum-2) > 1e-9 {
+			t.Errorf("neuron %d: weights sum to %v, want 2", j, sum)
+		}
+	}
+
+	// A budget out of reach leaves every weight at its bound
+	layer = constrained(rand.New(rand.NewSource(10)), Constraint{Kind: Budget, Target: 100})
+	layer.Constrain(nil)
+	for _, conn := range layer.Neurons[0].Connections {
+		if conn.Weight != 1 {
+			t.Errorf("weights %v, want all at the upper bound", layer.Neurons[0].weights())
+			break
+		}
+	}
+}
+
+func TestSoftBounds(t *testing.T) {
+	layer := constrained(rand.New(rand.NewSource(11)), Constraint{Kind: SoftBounds})
+	n := &layer.Neurons[0]
+	n.Connections[0].Weight, n.Connections[1].Weight, n.Connections[2].Weight = 0.9, -0.9, 0
+	old := layer.Weights()
+	// The same raw change of 0.2 on every weight
+	for i := range n.Connections {
+		n.Connections[i].Weight += 0.2
+	}
+	layer.Constrain(old)
+
+	// Near the upper bound the step shrinks by (1-w)/2; near the lower bound
+	// an increase keeps most of it
+	for i, want := range []float64{0.9 + 0.2*0.1/2, -0.9 + 0.2*1.9/2, 0.2 * 0.5} {
+		if got := n.Connections[i].Weight; math.Abs(got-want) > 1e-12 {
+			t.Errorf("weight %d: %v, want %v", i, got, want)
+		}
+	}
+
+	// Without the old weights soft bounds do nothing
+	w := n.weights()
+	layer.Constrain(nil)
+	for i, conn := range n.Connections {
+		if conn.Weight != w[i] {
+			t.Fatal("soft bounds changed weights without the weights before the update")
+		}
+	}
+}
+
+func TestConstraintsKeepBounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(12))
+	for _, c := range []Constraint{{Kind: L1Norm, Target: 50}, {Kind: L2Norm, Target: 50}, {Kind: Budget, Target: -50}} {
+		layer := constrained(rng, c)
+		layer.Constrain(nil)
+		for _, n := range layer.Neurons {
+			for _, conn := range n.Connections {
+				if conn.Weight < -1 || conn.Weight > 1 {
+					t.Errorf("%s: weight %v outside the bounds", c.Kind, conn.Weight)
+				}
+			}
+		}
+	}
+	if err := (Constraint{Kind: L2Norm}).Validate(); err == nil {
+		t.Error("l2 without a target: expected an error")
+	}
+	if err := (Constraint{Kind: "max"}).Validate(); err == nil {
+		t.Error("unknown kind: expected an error")
+	}
+}
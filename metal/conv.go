@@ -0,0 +1,262 @@
+package neuron
+
+import "fmt"
+
+// Shape lays a flat input out as Channels planes of Height×Width, channel
+// by channel and row by row within a channel
+type Shape struct {
+	Channels int `json:"channels"`
+	Height   int `json:"height"`
+	Width    int `json:"width"`
+}
+
+// Size is the number of values of the shape
+func (s Shape) Size() int {
+	return s.Channels * s.Height * s.Width
+}
+
+// index returns the flat index of channel c at row y and column x
+func (s Shape) index(c, y, x int) int {
+	return (c*s.Height+y)*s.Width + x
+}
+
+func (s Shape) validate() error {
+	if s.Channels < 1 || s.Height < 1 || s.Width < 1 {
+		return fmt.Errorf("shape %dx%dx%d must be positive", s.Channels, s.Height, s.Width)
+	}
+	return nil
+}
+
+// window returns the output shape of Kernel×Kernel windows moved by Stride
+// over in, each output plane being one of channels
+func window(in Shape, channels, kernel, stride int) (Shape, error) {
+	if err := in.validate(); err != nil {
+		return Shape{}, err
+	}
+	if kernel < 1 || stride < 1 {
+		return Shape{}, fmt.Errorf("kernel %d and stride %d must be positive", kernel, stride)
+	}
+	if kernel > in.Height || kernel > in.Width {
+		return Shape{}, fmt.Errorf("kernel %d does not fit a %dx%d input", kernel, in.Height, in.Width)
+	}
+	return Shape{
+		Channels: channels,
+		Height:   (in.Height-kernel)/stride + 1,
+		Width:    (in.Width-kernel)/stride + 1,
+	}, nil
+}
+
+// Convolution is a spiking convolutional layer: one neuron per channel and
+// position of the output, each reading a Kernel×Kernel window across all
+// input channels. The neurons of a channel share their weights and bias.
+// Every neuron keeps its own copy, which is what its dynamics and learning
+// rules act on; after each step the copies of a channel are reset to their
+// mean, so shared weights take the average of the updates made at every
+// position. Structural plasticity and rewiring break the sharing, so
+// Validate rejects structural plasticity on convolutional layers and
+// rewiring must not be used on them.
+type Convolution struct {
+	Input    Shape `json:"input"`
+	Channels int   `json:"channels"` // output channels, one kernel each
+	Kernel   int   `json:"kernel"`   // width and height of the window
+	Stride   int   `json:"stride"`
+}
+
+// Output returns the shape of the layer's spikes
+func (c Convolution) Output() (Shape, error) {
+	out, err := window(c.Input, c.Channels, c.Kernel, c.Stride)
+	if err != nil {
+		return Shape{}, fmt.Errorf("convolution: %w", err)
+	}
+	return out, nil
+}
+
+// Validate checks that the kernel fits the input
+func (c Convolution) Validate() error {
+	_, err := c.Output()
+	return err
+}
+
+// NewConvLayer builds a convolutional layer whose neurons copy the
+// parameters of template (thresholds, decay, bias, bounds and type; features
+// such as homeostasis are set afterwards with the Layer helpers). weight
+// returns the initial weight i of the kernel of a channel, where i runs over
+// input channels, then rows, then columns of the window.
+func NewConvLayer(c Convolution, template SpikingNeuron, weight func(channel, i int) float64) (*Layer, error) {
+	out, err := c.Output()
+	if err != nil {
+		return nil, err
+	}
+	k := c.Kernel
+	kernels := make([][]float64, c.Channels)
+	for ch := range kernels {
+		kernels[ch] = make([]float64, c.Input.Channels*k*k)
+		for i := range kernels[ch] {
+			kernels[ch][i] = weight(ch, i)
+		}
+	}
+
+	neurons := make([]SpikingNeuron, 0, out.Size())
+	for ch := 0; ch < out.Channels; ch++ {
+		for y := 0; y < out.Height; y++ {
+			for x := 0; x < out.Width; x++ {
+				n := parameters(template)
+				n.Sparse = true
+				for in := 0; in < c.Input.Channels; in++ {
+					for ky := 0; ky < k; ky++ {
+						for kx := 0; kx < k; kx++ {
+							n.Connections = append(n.Connections, Connection{
+								Weight:       kernels[ch][len(n.Connections)],
+								LastPreSpike: -100,
+								Source:       c.Input.index(in, y*c.Stride+ky, x*c.Stride+kx),
+							})
+						}
+					}
+				}
+				for i := range n.Connections {
+					n.clampWeight(&n.Connections[i])
+				}
+				neurons = append(neurons, n)
+			}
+		}
+	}
+	layer := NewLayer(neurons)
+	layer.Conv = &c
+	layer.Inputs = c.Input.Size()
+	return layer, nil
+}
+
+// parameters copies the parameters of a neuron, without its connections,
+// state or features
+func parameters(t SpikingNeuron) SpikingNeuron {
+	return SpikingNeuron{
+		Threshold:        t.Threshold,
+		Decay:            t.Decay,
+		Bias:             t.Bias,
+		RefractoryPeriod: t.RefractoryPeriod,
+		MinWeight:        t.MinWeight,
+		MaxWeight:        t.MaxWeight,
+		MinBias:          t.MinBias,
+		MaxBias:          t.MaxBias,
+		Type:             t.Type,
+	}
+}
+
+// Kernel returns the shared weights of a channel of a convolutional layer,
+// in the order NewConvLayer draws them
+func (l *Layer) Kernel(channel int) []float64 {
+	positions := len(l.Neurons) / l.Conv.Channels
+	n := &l.Neurons[channel*positions]
+	kernel := make([]float64, len(n.Connections))
+	for i, c := range n.Connections {
+		kernel[i] = c.Weight
+	}
+	return kernel
+}
+
+// tie resets the weights and bias of every channel of a convolutional layer
+// to their mean over the channel's positions
+func (l *Layer) tie() {
+	if l.Conv == nil || l.Conv.Channels < 1 {
+		return
+	}
+	positions := len(l.Neurons) / l.Conv.Channels
+	for ch := 0; ch < l.Conv.Channels; ch++ {
+		neurons := l.Neurons[ch*positions : (ch+1)*positions]
+		bias := 0.0
+		for j := range neurons {
+			bias += neurons[j].Bias
+		}
+		bias /= float64(positions)
+		for j := range neurons {
+			neurons[j].Bias = bias
+		}
+		for i := range neurons[0].Connections {
+			w := 0.0
+			for j := range neurons {
+				w += neurons[j].Connections[i].Weight
+			}
+			w /= float64(positions)
+			for j := range neurons {
+				neurons[j].Connections[i].Weight = w
+				neurons[j].clampWeight(&neurons[j].Connections[i])
+			}
+		}
+	}
+}
+
+// Pooling is spiking max pooling: one neuron per channel and Size×Size
+// window of the input, which fires whenever any input of its window spikes.
+// Pooling neurons have no dynamics or learning of their own; their
+// connections only record the window, with weight 1.
+type Pooling struct {
+	Input  Shape `json:"input"`
+	Size   int   `json:"size"`
+	Stride int   `json:"stride"`
+}
+
+// Output returns the shape of the layer's spikes
+func (p Pooling) Output() (Shape, error) {
+	out, err := window(p.Input, p.Input.Channels, p.Size, p.Stride)
+	if err != nil {
+		return Shape{}, fmt.Errorf("pooling: %w", err)
+	}
+	return out, nil
+}
+
+// Validate checks that the window fits the input
+func (p Pooling) Validate() error {
+	_, err := p.Output()
+	return err
+}
+
+// NewPoolLayer builds a pooling layer
+func NewPoolLayer(p Pooling) (*Layer, error) {
+	out, err := p.Output()
+	if err != nil {
+		return nil, err
+	}
+	neurons := make([]SpikingNeuron, 0, out.Size())
+	for ch := 0; ch < out.Channels; ch++ {
+		for y := 0; y < out.Height; y++ {
+			for x := 0; x < out.Width; x++ {
+				// Fixed unit weights onto a unit threshold keep the
+				// neurons a fair stand-in for trainers that simulate
+				// them as ordinary neurons
+				n := SpikingNeuron{Threshold: 1, MinWeight: 1, MaxWeight: 1, Sparse: true}
+				for ky := 0; ky < p.Size; ky++ {
+					for kx := 0; kx < p.Size; kx++ {
+						n.Connections = append(n.Connections, Connection{
+							Weight:       1,
+							LastPreSpike: -100,
+							Source:       p.Input.index(ch, y*p.Stride+ky, x*p.Stride+kx),
+						})
+					}
+				}
+				neurons = append(neurons, n)
+			}
+		}
+	}
+	layer := NewLayer(neurons)
+	layer.Pool = &p
+	layer.Inputs = p.Input.Size()
+	return layer, nil
+}
+
+// pool runs a pooling layer for one step
+func (l *Layer) pool(inputs []float64, currentTime int) []int {
+	spikes := make([]int, len(l.Neurons))
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+		n.MembranePotential = 0
+		for _, c := range n.Connections {
+			n.MembranePotential = max(n.MembranePotential, inputs[c.Source])
+		}
+		n.Fired = n.MembranePotential >= 1
+		if n.Fired {
+			n.LastSpikeTime = currentTime
+			spikes[j] = 1
+		}
+	}
+	return spikes
+}
@@ -0,0 +1,144 @@
+package neuron
+
+import "testing"
+
+func TestConvolutionShapes(t *testing.T) {
+	for _, tc := range []struct {
+		c    Convolution
+		want Shape
+	}{
+		{Convolution{Input: Shape{1, 28, 28}, Channels: 8, Kernel: 5, Stride: 2}, Shape{8, 12, 12}},
+		{Convolution{Input: Shape{3, 5, 7}, Channels: 2, Kernel: 3, Stride: 1}, Shape{2, 3, 5}},
+		{Convolution{Input: Shape{1, 4, 4}, Channels: 1, Kernel: 4, Stride: 3}, Shape{1, 1, 1}},
+	} {
+		got, err := tc.c.Output()
+		if err != nil {
+			t.Fatalf("%+v: %v", tc.c, err)
+		}
+		if got != tc.want {
+			t.Errorf("%+v: output %+v, want %+v", tc.c, got, tc.want)
+		}
+	}
+	if got, err := (Pooling{Input: Shape{8, 12, 12}, Size: 2, Stride: 2}).Output(); err != nil || got != (Shape{8, 6, 6}) {
+		t.Errorf("pooling output %+v (%v), want 8x6x6", got, err)
+	}
+
+	for _, c := range []Convolution{
+		{Input: Shape{1, 4, 4}, Channels: 1, Kernel: 5, Stride: 1},
+		{Input: Shape{1, 4, 4}, Channels: 1, Kernel: 2, Stride: 0},
+		{Input: Shape{0, 4, 4}, Channels: 1, Kernel: 2, Stride: 1},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+	if err := (Pooling{Input: Shape{1, 2, 2}, Size: 3, Stride: 1}).Validate(); err == nil {
+		t.Error("pooling window larger than its input: expected an error")
+	}
+}
+
+func TestConvolutionForward(t *testing.T) {
+	// Two channels over a 3x3 image with 2x2 windows: the first sums its
+	// window, the second only reads the top left pixel
+	c := Convolution{Input: Shape{1, 3, 3}, Channels: 2, Kernel: 2, Stride: 1}
+	template := SpikingNeuron{Threshold: 1.5, MinWeight: -2, MaxWeight: 2, MinBias: -1, MaxBias: 1}
+	layer, err := NewConvLayer(c, template, func(channel, i int) float64 {
+		if channel == 0 || i == 0 {
+			return 1
+		}
+		return 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layer.Neurons) != 8 || layer.Inputs != 9 {
+		t.Fatalf("%d neurons reading %d inputs, want 8 reading 9", len(layer.Neurons), layer.Inputs)
+	}
+	layer.SetNoise(Noise{Kind: NoNoise})
+
+	image := []float64{
+		1, 1, 0,
+		0, 0, 0,
+		1, 0, 1,
+	}
+	spikes, err := layer.Forward(image, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{
+		1, 0, // window sums 2 1
+		0, 0, //             1 1
+		0, 0, // top left pixels 1 1, below the threshold
+		0, 0, //                 0 0
+	}
+	for i := range want {
+		if spikes[i] != want[i] {
+			t.Fatalf("spikes %v, want %v", spikes, want)
+		}
+	}
+}
+
+func TestConvolutionSharesWeights(t *testing.T) {
+	c := Convolution{Input: Shape{1, 4, 4}, Channels: 2, Kernel: 2, Stride: 2}
+	template := SpikingNeuron{Threshold: 0.5, MinWeight: -2, MaxWeight: 2, MinBias: -1, MaxBias: 1}
+	layer, err := NewConvLayer(c, template, func(channel, i int) float64 { return 0.1 * float64(channel+i) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the top left quadrant is active, so learning only touches the
+	// first position of each channel before the weights are tied again
+	image := make([]float64, 16)
+	image[0], image[1], image[4], image[5] = 1, 1, 1, 1
+	for step := 0; step < 5; step++ {
+		if _, err := layer.Forward(image, step, 0.1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if layer.Kernel(1)[0] == 0.1 {
+		t.Fatal("learning left the kernel unchanged; the test proves nothing")
+	}
+	positions := len(layer.Neurons) / c.Channels
+	for ch := 0; ch < c.Channels; ch++ {
+		kernel := layer.Kernel(ch)
+		for p := 0; p < positions; p++ {
+			n := layer.Neurons[ch*positions+p]
+			for i, conn := range n.Connections {
+				if conn.Weight != kernel[i] {
+					t.Fatalf("channel %d position %d weight %d: %v, kernel has %v", ch, p, i, conn.Weight, kernel[i])
+				}
+			}
+			if n.Bias != layer.Neurons[ch*positions].Bias {
+				t.Fatalf("channel %d position %d: bias %v differs within the channel", ch, p, n.Bias)
+			}
+		}
+	}
+}
+
+func TestPoolingForward(t *testing.T) {
+	layer, err := NewPoolLayer(Pooling{Input: Shape{2, 2, 4}, Size: 2, Stride: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []float64{
+		0, 0, 0, 1, // channel 0
+		0, 0, 0, 0,
+		0, 0, 0, 0, // channel 1
+		1, 0, 0, 0,
+	}
+	spikes, err := layer.Forward(input, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{0, 1, 1, 0}
+	for i := range want {
+		if spikes[i] != want[i] {
+			t.Fatalf("spikes %v, want %v", spikes, want)
+		}
+	}
+	if layer.Neurons[1].LastSpikeTime != 3 {
+		t.Errorf("last spike time %d, want 3", layer.Neurons[1].LastSpikeTime)
+	}
+	if _, err := layer.Forward(input[:8], 4, 0); err == nil {
+		t.Error("input of the wrong width: expected an error")
+	}
+}
@@ -0,0 +1,223 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// NeuronType marks a neuron as excitatory or inhibitory. Under Dale's law all
+// outgoing connections of a neuron share its sign. Untyped neurons and
+// connections keep the free-signed behaviour.
+type NeuronType string
+
+const (
+	Untyped    NeuronType = ""
+	Excitatory NeuronType = "excitatory"
+	Inhibitory NeuronType = "inhibitory"
+)
+
+// PairRule scales the built-in potentiation and depression of a connection.
+// A negative LTP makes the rule anti-Hebbian.
+type PairRule struct {
+	LTP float64 `json:"ltp"`
+	LTD float64 `json:"ltd"`
+}
+
+// DaleRules are the learning rules of typed connections, by presynaptic and
+// postsynaptic type. Typed connections learn on the magnitude of their
+// weight: potentiation makes an inhibitory connection more negative.
+type DaleRules struct {
+	EE PairRule `json:"ee"`
+	EI PairRule `json:"ei"`
+	IE PairRule `json:"ie"`
+	II PairRule `json:"ii"`
+}
+
+// DefaultDaleRules learn every pairing at the built-in rates, except
+// inhibitory-to-inhibitory connections, which stay fixed
+func DefaultDaleRules() *DaleRules {
+	return &DaleRules{
+		EE: PairRule{LTP: 1, LTD: 1},
+		EI: PairRule{LTP: 1, LTD: 1},
+		IE: PairRule{LTP: 1, LTD: 1},
+	}
+}
+
+// rule returns the rule for a connection from pre onto post
+func (r *DaleRules) rule(pre, post NeuronType) PairRule {
+	if r == nil {
+		r = DefaultDaleRules()
+	}
+	switch {
+	case pre == Excitatory && post == Inhibitory:
+		return r.EI
+	case pre == Inhibitory && post == Inhibitory:
+		return r.II
+	case pre == Inhibitory:
+		return r.IE
+	}
+	return r.EE
+}
+
+// WeightBounds returns the range a connection's weight may take: the
+// neuron's bounds, cut at zero on the side the presynaptic type forbids
+func (n *SpikingNeuron) WeightBounds(c Connection) (float64, float64) {
+	switch c.Pre {
+	case Excitatory:
+		return math.Max(0, n.MinWeight), n.MaxWeight
+	case Inhibitory:
+		return n.MinWeight, math.Min(0, n.MaxWeight)
+	}
+	return n.MinWeight, n.MaxWeight
+}
+
+// clampWeight keeps c inside its bounds
+func (n *SpikingNeuron) clampWeight(c *Connection) {
+	lo, hi := n.WeightBounds(*c)
+	c.Weight = clamp(c.Weight, lo, hi)
+}
+
+// typedUpdate applies the built-in rule to the magnitude of a typed weight:
+// a change of amount scaled by how far the magnitude is from its bound
+func (n *SpikingNeuron) typedUpdate(c *Connection, amount float64) {
+	lo, hi := n.WeightBounds(*c)
+	bound, sign := hi, 1.0
+	if c.Pre == Inhibitory {
+		bound, sign = -lo, -1.0
+	}
+	if bound <= 0 {
+		c.Weight = 0
+		return
+	}
+	m := math.Abs(c.Weight)
+	m = clamp(m+amount*(1-m/bound), 0, bound)
+	c.Weight = sign * m
+}
+
+// ApplyDale records the presynaptic type on every connection and flips
+// weights to the sign it requires. Connections of the first layer come from
+// inputs of type input; recurrent connections from the layer itself.
+func (n *Network) ApplyDale(input NeuronType) {
+	pre := func(l, i int) NeuronType {
+		if l == 0 {
+			return input
+		}
+		return n.Layers[l-1].Neurons[i].Type
+	}
+	for l, layer := range n.Layers {
+		for j := range layer.Neurons {
+			neuron := &layer.Neurons[j]
+			for i := range neuron.Connections {
+				c := &neuron.Connections[i]
+				c.Pre = pre(l, neuron.InputIndex(i))
+				signDale(c)
+				neuron.clampWeight(c)
+			}
+			for i := range neuron.Recurrent {
+				c := &neuron.Recurrent[i]
+				c.Pre = layer.Neurons[i].Type
+				signDale(c)
+				neuron.clampWeight(c)
+			}
+		}
+	}
+}
+
+func signDale(c *Connection) {
+	switch c.Pre {
+	case Excitatory:
+		c.Weight = math.Abs(c.Weight)
+	case Inhibitory:
+		c.Weight = -math.Abs(c.Weight)
+	}
+}
+
+// EIConfig describes a network of layers of excitatory and inhibitory neurons
+type EIConfig struct {
+	Inputs           int
+	Sizes            []int   // neurons per layer
+	Excitatory       float64 // fraction of excitatory neurons in each layer, e.g. 0.8
+	Weight           float64 // mean excitatory weight
+	Balance          float64 // total inhibitory over total excitatory input; 1 balances them
+	Recurrent        bool    // connect every layer to itself
+	Threshold        float64
+	Decay            float64
+	RefractoryPeriod int
+	Rules            *DaleRules // nil uses DefaultDaleRules
+}
+
+// NewEINetwork builds a network obeying Dale's law. The first neurons of each
+// layer are excitatory and the rest inhibitory; inputs are excitatory.
+// Inhibitory weights are scaled so that, for equal activity, the inhibition a
+// neuron receives is Balance times its excitation. Weights vary uniformly by
+// ±50% around their mean, drawn from rng or the global source when nil, and
+// are clamped to the neuron's bounds.
+func NewEINetwork(cfg EIConfig, rng *rand.Rand) (*Network, error) {
+	if cfg.Inputs < 1 || len(cfg.Sizes) == 0 {
+		return nil, fmt.Errorf("ei: need inputs and at least one layer")
+	}
+	if cfg.Excitatory <= 0 || cfg.Excitatory > 1 {
+		return nil, fmt.Errorf("ei: excitatory fraction must be in (0, 1], got %v", cfg.Excitatory)
+	}
+	// weights draws the weights onto a neuron from presynaptic neurons of
+	// the given types
+	weights := func(pres []NeuronType) []Connection {
+		excitatory := 0
+		for _, t := range pres {
+			if t != Inhibitory {
+				excitatory++
+			}
+		}
+		inhibitory := len(pres) - excitatory
+		connections := make([]Connection, len(pres))
+		for i, t := range pres {
+			w := cfg.Weight * (0.5 + uniform(rng))
+			if t == Inhibitory {
+				w *= -cfg.Balance * float64(excitatory) / float64(inhibitory)
+			}
+			connections[i] = Connection{Weight: w, LastPreSpike: -100, Pre: t}
+		}
+		return connections
+	}
+
+	pres := make([]NeuronType, cfg.Inputs)
+	for i := range pres {
+		pres[i] = Excitatory
+	}
+	var layers []*Layer
+	for l, size := range cfg.Sizes {
+		if size < 1 {
+			return nil, fmt.Errorf("ei: layer %d has no neurons", l)
+		}
+		types := make([]NeuronType, size)
+		for j := range types {
+			types[j] = Inhibitory
+			if j < int(math.Round(cfg.Excitatory*float64(size))) {
+				types[j] = Excitatory
+			}
+		}
+		neurons := make([]SpikingNeuron, size)
+		for j := range neurons {
+			n := NewSpikingNeuron(0, cfg.Threshold, cfg.Decay, 0, cfg.RefractoryPeriod)
+			n.Type = types[j]
+			n.Connections = weights(pres)
+			if cfg.Recurrent {
+				n.Recurrent = weights(types)
+				n.Recurrent[j].Weight = 0
+			}
+			for i := range n.Connections {
+				n.clampWeight(&n.Connections[i])
+			}
+			for i := range n.Recurrent {
+				n.clampWeight(&n.Recurrent[i])
+			}
+			neurons[j] = *n
+		}
+		layer := NewLayer(neurons)
+		layer.Rules = cfg.Rules
+		layers = append(layers, layer)
+		pres = types
+	}
+	return NewNetwork(layers)
+}
@@ -0,0 +1,101 @@
+package neuron
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestApplyDaleSparse(t *testing.T) {
+	first := NewLayer([]SpikingNeuron{
+		{Type: Excitatory}, {Type: Inhibitory}, {Type: Excitatory},
+	})
+	second := NewLayer(make([]SpikingNeuron, 2))
+	for j := range second.Neurons {
+		second.Neurons[j] = *NewSpikingNeuron(3, 1, 0.9, 0, 0)
+	}
+	edges := EdgeList{{Pre: 1, Post: 0, Weight: 0.5}, {Pre: 0, Post: 1, Weight: -0.5}, {Pre: 1, Post: 1, Weight: 0.5}}
+	if err := second.Wire(3, edges, edges.Weights(), nil); err != nil {
+		t.Fatal(err)
+	}
+	net, err := NewNetwork([]*Layer{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.ApplyDale(Excitatory)
+
+	for j, n := range second.Neurons {
+		for i, c := range n.Connections {
+			want := first.Neurons[n.InputIndex(i)].Type
+			if c.Pre != want {
+				t.Errorf("neuron %d connection %d from input %d: pre %q, want %q", j, i, n.InputIndex(i), c.Pre, want)
+			}
+			if (want == Inhibitory) != (c.Weight < 0) {
+				t.Errorf("neuron %d connection %d: weight %v has the wrong sign for %q", j, i, c.Weight, want)
+			}
+		}
+	}
+}
+
+func TestDaleUnderLearning(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	net, err := NewEINetwork(EIConfig{
+		Inputs: 6, Sizes: []int{10, 5}, Excitatory: 0.8, Weight: 0.4, Balance: 1,
+		Recurrent: true, Threshold: 0.8, Decay: 0.8, RefractoryPeriod: 1,
+	}, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetSeed(3)
+	fixed := map[[4]int]float64{} // inhibitory-to-inhibitory recurrent weights
+	for l, layer := range net.Layers {
+		for j, n := range layer.Neurons {
+			for i, c := range n.Recurrent {
+				if c.Pre == Inhibitory && n.Type == Inhibitory {
+					fixed[[4]int{l, j, i}] = c.Weight
+				}
+			}
+		}
+	}
+	if len(fixed) == 0 {
+		t.Fatal("no inhibitory-to-inhibitory connections to check")
+	}
+	before := net.Layers[0].Weights()
+
+	for step := 0; step < 300; step++ {
+		input := make([]float64, 6)
+		for i := range input {
+			if rng.Float64() < 0.4 {
+				input[i] = 1
+			}
+		}
+		if _, err := net.Forward(input, step, 0.2); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed := false
+	for l, layer := range net.Layers {
+		for j, n := range layer.Neurons {
+			check := func(kind string, i int, c Connection) {
+				if c.Pre == Excitatory && c.Weight < 0 || c.Pre == Inhibitory && c.Weight > 0 {
+					t.Errorf("layer %d neuron %d %s %d from %s has weight %v", l, j, kind, i, c.Pre, c.Weight)
+				}
+			}
+			for i, c := range n.Connections {
+				check("connection", i, c)
+				if l == 0 && c.Weight != before[j][i] {
+					changed = true
+				}
+			}
+			for i, c := range n.Recurrent {
+				check("recurrent connection", i, c)
+				if w, ok := fixed[[4]int{l, j, i}]; ok && c.Weight != w {
+					t.Errorf("layer %d neuron %d: inhibitory-to-inhibitory weight moved from %v to %v", l, j, w, c.Weight)
+				}
+			}
+		}
+	}
+	if !changed {
+		t.Fatal("learning left the weights unchanged; the test proves nothing")
+	}
+}
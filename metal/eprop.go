@@ -0,0 +1,328 @@
+package neuron
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// EPropTrainer learns online with e-prop: every connection of the last layer
+// keeps an eligibility trace computed forward in time from its own pre- and
+// postsynaptic activity, and a learning signal broadcast from the readout
+// error turns the traces into weight updates at every step. Memory does not
+// grow with the length of the sequence, so the trainer runs on streams of any
+// length.
+//
+// The last layer may be recurrent (see Layer.Connect); its feed-forward
+// weights, recurrent weights and biases are trained together with a leaky
+// linear readout y = ReadoutDecay·y + Readout·z + ReadoutBias of its spikes.
+// Earlier layers run as fixed encoders. Like BPTTTrainer the trainer simulates
+// the deterministic part of the neuron dynamics on its own state, without
+// noise, refractoriness or the neurons' online plasticity.
+type EPropTrainer struct {
+	Net          *Network
+	Surrogate    Surrogate
+	Optimizer    Optimizer
+	ReadoutDecay float64     // leak of the readout and of the eligibility traces
+	Readout      [][]float64 // readout weights, one row per output
+	ReadoutBias  []float64
+	Feedback     [][]float64 // learning signal weights, shaped like Readout; nil uses Readout
+	UpdateEvery  int         // steps between optimizer steps; 0 updates every step
+
+	potential [][]float64 // per layer, membrane potentials
+	spikes    [][]float64 // per layer, spikes of the last step
+	output    []float64   // readout of the last step
+	trace     [][]float64 // per trained neuron, presynaptic traces filtered by its decay
+	elig      [][]float64 // per trained neuron, eligibility traces filtered by ReadoutDecay
+	zTrace    []float64   // spikes of the last layer filtered by ReadoutDecay
+	oneTrace  float64     // a constant input filtered by ReadoutDecay
+	grads     []float64
+	pending   int
+}
+
+// NewEPropTrainer creates a trainer with the given number of readout outputs.
+// The readout weights are drawn from the network's random source.
+func NewEPropTrainer(net *Network, outputs int, readoutDecay float64, surrogate Surrogate, optimizer Optimizer) (*EPropTrainer, error) {
+	if len(net.Layers) == 0 {
+		return nil, errors.New("eprop: network has no layers")
+	}
+	if outputs < 1 {
+		return nil, fmt.Errorf("eprop: need at least one output, got %d", outputs)
+	}
+	if readoutDecay < 0 || readoutDecay >= 1 {
+		return nil, fmt.Errorf("eprop: readout decay must be in [0, 1), got %v", readoutDecay)
+	}
+	last := net.Layers[len(net.Layers)-1]
+	for j, n := range last.Neurons {
+		if len(n.Recurrent) > 0 && len(n.Recurrent) != len(last.Neurons) {
+			return nil, fmt.Errorf("eprop: neuron %d has %d recurrent connections for %d neurons", j, len(n.Recurrent), len(last.Neurons))
+		}
+	}
+
+	tr := &EPropTrainer{
+		Net:          net,
+		Surrogate:    surrogate,
+		Optimizer:    optimizer,
+		ReadoutDecay: readoutDecay,
+		Readout:      make([][]float64, outputs),
+		ReadoutBias:  make([]float64, outputs),
+	}
+	scale := 1 / math.Sqrt(float64(len(last.Neurons)))
+	rng := net.Rand()
+	for k := range tr.Readout {
+		tr.Readout[k] = make([]float64, len(last.Neurons))
+		for j := range tr.Readout[k] {
+			tr.Readout[k][j] = normal(rng) * scale
+		}
+	}
+	tr.Reset()
+	return tr, nil
+}
+
+// normal draws from rng, or from the global source for unseeded networks
+func normal(rng *rand.Rand) float64 {
+	if rng == nil {
+		return rand.NormFloat64()
+	}
+	return rng.NormFloat64()
+}
+
+// RandomFeedback replaces the symmetric learning signal by fixed random
+// feedback weights drawn from the network's random source (random e-prop)
+func (tr *EPropTrainer) RandomFeedback() {
+	scale := 1 / math.Sqrt(float64(len(tr.Readout[0])))
+	rng := tr.Net.Rand()
+	tr.Feedback = make([][]float64, len(tr.Readout))
+	for k := range tr.Feedback {
+		tr.Feedback[k] = make([]float64, len(tr.Readout[k]))
+		for j := range tr.Feedback[k] {
+			tr.Feedback[k][j] = normal(rng) * scale
+		}
+	}
+}
+
+// Reset returns the simulated neurons, the readout and all traces to rest,
+// as at the start of a new sequence. Gradients not yet applied are kept.
+func (tr *EPropTrainer) Reset() {
+	tr.potential = make([][]float64, len(tr.Net.Layers))
+	tr.spikes = make([][]float64, len(tr.Net.Layers))
+	for l, layer := range tr.Net.Layers {
+		tr.potential[l] = make([]float64, len(layer.Neurons))
+		tr.spikes[l] = make([]float64, len(layer.Neurons))
+	}
+	last := tr.Net.Layers[len(tr.Net.Layers)-1]
+	tr.trace = make([][]float64, len(last.Neurons))
+	tr.elig = make([][]float64, len(last.Neurons))
+	for j, n := range last.Neurons {
+		size := len(n.Connections) + len(n.Recurrent) + 1
+		tr.trace[j] = make([]float64, size)
+		tr.elig[j] = make([]float64, size)
+	}
+	tr.zTrace = make([]float64, len(last.Neurons))
+	tr.oneTrace = 0
+	tr.output = make([]float64, len(tr.Readout))
+}
+
+// Output returns the readout of the last step
+func (tr *EPropTrainer) Output() []float64 {
+	return append([]float64(nil), tr.output...)
+}
+
+// Step advances one timestep and returns the readout. When target is not nil
+// the squared error against it is accumulated into the gradients, applied by
+// the optimizer every UpdateEvery steps, and returned as the loss.
+func (tr *EPropTrainer) Step(input, target []float64) ([]float64, float64, error) {
+	if target != nil && len(target) != len(tr.Readout) {
+		return nil, 0, fmt.Errorf("eprop: target has %d values for %d outputs", len(target), len(tr.Readout))
+	}
+	last := len(tr.Net.Layers) - 1
+	for l, layer := range tr.Net.Layers {
+		next, err := tr.layerStep(l, layer, input, l == last)
+		if err != nil {
+			return nil, 0, err
+		}
+		input = next
+	}
+
+	// Leaky readout of the last layer's spikes
+	z := tr.spikes[last]
+	k := tr.ReadoutDecay
+	for j, s := range z {
+		tr.zTrace[j] = k*tr.zTrace[j] + s
+	}
+	tr.oneTrace = k*tr.oneTrace + 1
+	for o, row := range tr.Readout {
+		y := k*tr.output[o] + tr.ReadoutBias[o]
+		for j, w := range row {
+			y += w * z[j]
+		}
+		tr.output[o] = y
+	}
+
+	if target == nil {
+		return tr.Output(), 0, nil
+	}
+	loss := tr.accumulate(target)
+	tr.pending++
+	if tr.pending >= max(tr.UpdateEvery, 1) {
+		tr.Update()
+	}
+	return tr.Output(), loss, nil
+}
+
+// layerStep runs one layer for one step and returns its spikes. For the
+// trained layer it also advances the eligibility traces.
+func (tr *EPropTrainer) layerStep(l int, layer *Layer, input []float64, trained bool) ([]float64, error) {
+	v, previous := tr.potential[l], tr.spikes[l]
+	spikes := make([]float64, len(layer.Neurons))
+	dt := tr.Net.TimeStep()
+	for j := range layer.Neurons {
+		n := &layer.Neurons[j]
+		if err := n.checkInputs(len(input)); err != nil {
+			return nil, fmt.Errorf("eprop: layer %d neuron %d: %w", l, j, err)
+		}
+		pre := n.presynaptic(input)
+		decay := kept(n.Decay, dt)
+		v[j] *= decay
+		for i, x := range pre {
+			v[j] += x * n.Connections[i].Weight
+		}
+		for i := range n.Recurrent {
+			v[j] += previous[i] * n.Recurrent[i].Weight
+		}
+		v[j] += n.Bias * dt
+
+		threshold := n.Threshold + n.AdaptiveThreshold
+		if trained {
+			// The presynaptic trace of every parameter follows the membrane
+			// decay; the eligibility is that trace times the pseudo-derivative
+			// of the spike, low-pass filtered like the readout
+			trace, elig := tr.trace[j], tr.elig[j]
+			psi := tr.Surrogate(v[j] - threshold)
+			p := 0
+			for _, x := range pre {
+				trace[p] = decay*trace[p] + x
+				p++
+			}
+			for i := range n.Recurrent {
+				trace[p] = decay*trace[p] + previous[i]
+				p++
+			}
+			trace[p] = decay*trace[p] + dt
+			for p := range trace {
+				elig[p] = tr.ReadoutDecay*elig[p] + psi*trace[p]
+			}
+		}
+
+		if v[j] >= threshold {
+			spikes[j] = 1
+			v[j] = 0
+		}
+	}
+	tr.spikes[l] = spikes
+	return spikes, nil
+}
+
+// accumulate adds the gradients of the squared readout error at this step,
+// laid out like parameters, and returns the error
+func (tr *EPropTrainer) accumulate(target []float64) float64 {
+	if tr.grads == nil {
+		tr.grads = make([]float64, len(tr.parameters()))
+	}
+	errs := make([]float64, len(target))
+	loss := 0.0
+	for o, y := range tr.output {
+		errs[o] = y - target[o]
+		loss += 0.5 * errs[o] * errs[o]
+	}
+
+	feedback := tr.Feedback
+	if feedback == nil {
+		feedback = tr.Readout
+	}
+	p := 0
+	for j, elig := range tr.elig {
+		signal := 0.0
+		for o, e := range errs {
+			signal += feedback[o][j] * e
+		}
+		for _, e := range elig {
+			tr.grads[p] += signal * e
+			p++
+		}
+	}
+	for o, e := range errs {
+		for j := range tr.Readout[o] {
+			tr.grads[p] += e * tr.zTrace[j]
+			p++
+		}
+	}
+	for _, e := range errs {
+		tr.grads[p] += e * tr.oneTrace
+		p++
+	}
+	return loss
+}
+
+// Update applies the accumulated gradients, if any, with the optimizer
+func (tr *EPropTrainer) Update() {
+	if tr.pending == 0 {
+		return
+	}
+	params := tr.parameters()
+	values := make([]float64, len(params))
+	for i, p := range params {
+		values[i] = *p
+		tr.grads[i] /= float64(tr.pending)
+	}
+	last := tr.Net.Layers[len(tr.Net.Layers)-1]
+	old := last.Weights()
+	tr.Net.track(tr.Net.Time, func() {
+		tr.Optimizer.Step(values, tr.grads)
+		for i, p := range params {
+			*p = values[i]
+		}
+
+		for j := range last.Neurons {
+			n := &last.Neurons[j]
+			for i := range n.Connections {
+				n.clampWeight(&n.Connections[i])
+			}
+			for i := range n.Recurrent {
+				n.clampWeight(&n.Recurrent[i])
+			}
+			n.Bias = clamp(n.Bias, n.MinBias, n.MaxBias)
+		}
+		last.Constrain(old)
+	})
+	clear(tr.grads)
+	tr.pending = 0
+}
+
+// parameters lists the trained values in a fixed order: for every neuron of
+// the last layer its weights, recurrent weights and bias, then the readout
+// weights and biases
+func (tr *EPropTrainer) parameters() []*float64 {
+	var params []*float64
+	last := tr.Net.Layers[len(tr.Net.Layers)-1]
+	for j := range last.Neurons {
+		n := &last.Neurons[j]
+		for i := range n.Connections {
+			params = append(params, &n.Connections[i].Weight)
+		}
+		for i := range n.Recurrent {
+			params = append(params, &n.Recurrent[i].Weight)
+		}
+		params = append(params, &n.Bias)
+	}
+	for o := range tr.Readout {
+		for j := range tr.Readout[o] {
+			params = append(params, &tr.Readout[o][j])
+		}
+	}
+	for o := range tr.ReadoutBias {
+		params = append(params, &tr.ReadoutBias[o])
+	}
+	return params
+}
@@ -0,0 +1,109 @@
+package neuron
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// cueSequence shows the cue of one class on its own inputs for the first
+// half of the sequence, then background noise; the target asks for the class
+// during the second half, after the cue has gone
+func cueSequence(rng *rand.Rand, class, classes, inputs, steps int) (frames, targets [][]float64) {
+	for t := 0; t < steps; t++ {
+		frame := make([]float64, inputs)
+		for i := range frame {
+			frame[i] = rng.Float64() * 0.1
+			if t < steps/2 && i%classes == class {
+				frame[i] = 1
+			}
+		}
+		frames = append(frames, frame)
+		var target []float64
+		if t >= steps/2 {
+			target = make([]float64, classes)
+			target[class] = 1
+		}
+		targets = append(targets, target)
+	}
+	return frames, targets
+}
+
+// recall runs one sequence and returns the output with the largest readout
+// summed over the steps that have a target, and the mean loss over them
+func recall(t *testing.T, tr *EPropTrainer, frames, targets [][]float64, learn bool) (int, float64) {
+	t.Helper()
+	tr.Reset()
+	sum := make([]float64, len(tr.Readout))
+	loss, counted := 0.0, 0
+	for s, frame := range frames {
+		target := targets[s]
+		if !learn {
+			target = nil
+		}
+		out, _, err := tr.Step(frame, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if targets[s] == nil {
+			continue
+		}
+		for o, y := range out {
+			sum[o] += y
+			d := y - targets[s][o]
+			loss += 0.5 * d * d
+		}
+		counted++
+	}
+	best := 0
+	for o := range sum {
+		if sum[o] > sum[best] {
+			best = o
+		}
+	}
+	return best, loss / float64(counted)
+}
+
+func TestEPropLearnsDelayedRecall(t *testing.T) {
+	const classes, inputs, steps = 2, 6, 20
+	rng := rand.New(rand.NewSource(4))
+	net := denseNetwork(t, rng, inputs, 12)
+	net.Layers[0].Connect(func(post, pre int) float64 {
+		if post == pre {
+			return 0
+		}
+		return rng.NormFloat64() * 0.1
+	})
+	// The network is unseeded, so the readout is drawn from the global source
+	tr, err := NewEPropTrainer(net, classes, 0.8, FastSigmoid(2), &Adam{LearningRate: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.UpdateEvery = steps
+
+	evaluate := func() (float64, float64) {
+		correct, loss := 0, 0.0
+		for n := 0; n < 20; n++ {
+			class := n % classes
+			frames, targets := cueSequence(rng, class, classes, inputs, steps)
+			k, l := recall(t, tr, frames, targets, false)
+			if k == class {
+				correct++
+			}
+			loss += l
+		}
+		return float64(correct) / 20, loss / 20
+	}
+
+	_, before := evaluate()
+	for n := 0; n < 200; n++ {
+		frames, targets := cueSequence(rng, n%classes, classes, inputs, steps)
+		recall(t, tr, frames, targets, true)
+	}
+	acc, after := evaluate()
+	if after >= before {
+		t.Errorf("loss went from %.3f to %.3f, want it to fall", before, after)
+	}
+	if acc < 0.9 {
+		t.Errorf("accuracy = %.2f, want at least 0.9", acc)
+	}
+}
@@ -0,0 +1,78 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+)
+
+// EscapeKind selects how the firing rate of a stochastic neuron grows with
+// its potential
+type EscapeKind string
+
+const (
+	// ExponentialEscape fires at Rate·exp((v-θ)/Width): Rate at threshold,
+	// e times more for every Width above it
+	ExponentialEscape EscapeKind = "exponential"
+	// SigmoidEscape fires at Rate/(1+exp(-(v-θ)/Width)): half of the
+	// maximum Rate at threshold
+	SigmoidEscape EscapeKind = "sigmoid"
+)
+
+// Escape makes a neuron fire stochastically. Instead of spiking whenever
+// its potential v reaches the effective threshold θ, the neuron fires with
+// a rate per millisecond (its hazard) that grows smoothly with v-θ, so it
+// can fire below threshold and stay silent above it. Over a step of dt the
+// firing probability is 1-exp(-hazard·dt), which keeps the statistics of a
+// spike train independent of the timestep. Everything after the decision
+// (reset, refractoriness, learning) is as for deterministic neurons. The
+// draws come from the network's random source.
+type Escape struct {
+	Kind  EscapeKind `json:"kind"`
+	Rate  float64    `json:"rate"`  // spikes per ms: at threshold (exponential) or at most (sigmoid)
+	Width float64    `json:"width"` // potential over which the rate rises by a factor of e or through the sigmoid
+}
+
+// Validate checks the kind, rate and width
+func (e *Escape) Validate() error {
+	switch e.Kind {
+	case ExponentialEscape, SigmoidEscape:
+	default:
+		return fmt.Errorf("escape: unknown kind %q", e.Kind)
+	}
+	if e.Rate <= 0 || e.Width <= 0 {
+		return fmt.Errorf("escape: rate and width must be positive, got %v and %v", e.Rate, e.Width)
+	}
+	return nil
+}
+
+// Hazard returns the firing rate in spikes per millisecond at a potential
+// distance above the threshold (negative below it)
+func (e *Escape) Hazard(distance float64) float64 {
+	if e.Kind == SigmoidEscape {
+		return e.Rate / (1 + math.Exp(-distance/e.Width))
+	}
+	return e.Rate * math.Exp(distance/e.Width)
+}
+
+// Probability returns the probability of firing in a step of dt
+// milliseconds at a potential distance above the threshold
+func (e *Escape) Probability(distance, dt float64) float64 {
+	return -math.Expm1(-e.Hazard(distance) * dt)
+}
+
+// SetEscape makes every neuron of the layer stochastic with its own copy of e
+func (l *Layer) SetEscape(e Escape) {
+	for i := range l.Neurons {
+		c := e
+		l.Neurons[i].Escape = &c
+	}
+}
+
+// fires decides whether the neuron spikes at the given effective threshold;
+// draw is a uniform draw in [0, 1) used by stochastic neurons
+func (n *SpikingNeuron) fires(threshold, dt, draw float64) bool {
+	if n.Escape == nil {
+		return n.MembranePotential >= threshold
+	}
+	return draw < n.Escape.Probability(n.MembranePotential-threshold, dt)
+}
@@ -0,0 +1,165 @@
+package neuron
+
+import (
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"tinybrain/initializer"
+)
+
+// empirical returns the fraction of steps of dt in which a stochastic neuron
+// held at distance above its threshold fires
+func empirical(e Escape, distance, dt float64, trials int) float64 {
+	rng := rand.New(rand.NewPCG(11, 12))
+	n := NewSpikingNeuron(0, 1, 1, 0, 0)
+	n.Noise = &Noise{Kind: NoNoise}
+	n.Escape = &e
+	fired := 0
+	for t := 0; t < trials; t++ {
+		n.MembranePotential = n.Threshold + distance
+		n.AdaptiveThreshold = 0
+		fired += n.forward(nil, nil, t, 0, dt, 0, rng.Float64(), nil)
+	}
+	return float64(fired) / float64(trials)
+}
+
+func TestEscapeEmpiricalFiringProbability(t *testing.T) {
+	const trials = 20000
+	for _, e := range []Escape{
+		{Kind: ExponentialEscape, Rate: 0.2, Width: 0.1},
+		{Kind: SigmoidEscape, Rate: 0.8, Width: 0.1},
+	} {
+		for _, dt := range []float64{1, 0.1} {
+			previous := -1.0
+			for _, d := range []float64{-0.4, -0.2, -0.1, 0, 0.1, 0.2, 0.4} {
+				want := e.Probability(d, dt)
+				got := empirical(e, d, dt, trials)
+				tolerance := 4*math.Sqrt(want*(1-want)/trials) + 1e-3
+				if math.Abs(got-want) > tolerance {
+					t.Errorf("%s dt %v distance %v: fired in %.4f of steps, want %.4f", e.Kind, dt, d, got, want)
+				}
+				if got < previous {
+					t.Errorf("%s dt %v distance %v: probability %.4f fell from %.4f", e.Kind, dt, d, got, previous)
+				}
+				previous = got
+			}
+		}
+	}
+}
+
+func TestEscapeCurve(t *testing.T) {
+	exponential := Escape{Kind: ExponentialEscape, Rate: 0.2, Width: 0.1}
+	sigmoid := Escape{Kind: SigmoidEscape, Rate: 0.8, Width: 0.1}
+	for _, tc := range []struct {
+		e        Escape
+		distance float64
+		hazard   float64
+	}{
+		{exponential, 0, 0.2},
+		{exponential, 0.1, 0.2 * math.E},
+		{exponential, -0.2, 0.2 / (math.E * math.E)},
+		{sigmoid, 0, 0.4},
+		{sigmoid, 0.1, 0.8 / (1 + 1/math.E)},
+		{sigmoid, 10, 0.8},
+	} {
+		if h := tc.e.Hazard(tc.distance); math.Abs(h-tc.hazard) > 1e-9 {
+			t.Errorf("%s at %v: hazard %v, want %v", tc.e.Kind, tc.distance, h, tc.hazard)
+		}
+		if p, want := tc.e.Probability(tc.distance, 0.5), 1-math.Exp(-tc.hazard*0.5); math.Abs(p-want) > 1e-9 {
+			t.Errorf("%s at %v: probability %v, want %v", tc.e.Kind, tc.distance, p, want)
+		}
+	}
+
+	// Firing within a millisecond is as likely in ten steps of 0.1 as in one
+	for _, d := range []float64{-0.3, 0, 0.3} {
+		coarse := exponential.Probability(d, 1)
+		fine := 1 - math.Pow(1-exponential.Probability(d, 0.1), 10)
+		if math.Abs(coarse-fine) > 1e-12 {
+			t.Errorf("distance %v: %v in one step of 1 ms, %v in ten of 0.1 ms", d, coarse, fine)
+		}
+	}
+}
+
+func TestEscapeFiresBelowThreshold(t *testing.T) {
+	// A bias that holds the potential at half the threshold never fires a
+	// deterministic neuron; stochastic ones escape now and then, the same
+	// way for the same seed
+	run := func(escape bool) []int {
+		net, err := NewBuilder(1).Seed(4).
+			Layer("out", 10).Decay(initializer.Constant(TauDecay(10))).Bias(initializer.Constant(0.05)).With(func(l *Layer) error {
+			l.SetNoise(Noise{Kind: NoNoise})
+			if escape {
+				l.SetEscape(Escape{Kind: ExponentialEscape, Rate: 0.05, Width: 0.2})
+			}
+			return nil
+		}).Done().
+			Project(Input, "out", AllToAll{}, initializer.Constant(0)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs, err := net.Run(2000, func(int) []float64 { return []float64{0} }, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := make([]int, 10)
+		for _, output := range outputs {
+			for j, s := range output {
+				counts[j] += s
+			}
+		}
+		return counts
+	}
+	for j, c := range run(false) {
+		if c != 0 {
+			t.Fatalf("deterministic neuron %d fired %d times below threshold", j, c)
+		}
+	}
+	a, b := run(true), run(true)
+	total := 0
+	for j := range a {
+		if a[j] != b[j] {
+			t.Fatalf("neuron %d: %d and %d spikes from the same seed", j, a[j], b[j])
+		}
+		total += a[j]
+	}
+	if total == 0 {
+		t.Error("no stochastic neuron fired below threshold")
+	}
+}
+
+func TestEscapeValidate(t *testing.T) {
+	for _, e := range []Escape{
+		{},
+		{Kind: "step", Rate: 1, Width: 1},
+		{Kind: ExponentialEscape, Rate: 0, Width: 1},
+		{Kind: SigmoidEscape, Rate: 1, Width: 0},
+	} {
+		if err := e.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", e)
+		}
+	}
+	if err := (&Escape{Kind: SigmoidEscape, Rate: 1, Width: 0.1}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadRejectsInvalidEscape(t *testing.T) {
+	for _, escape := range []Escape{
+		{Kind: SigmoidEscape, Rate: 1, Width: 0},
+		{Kind: ExponentialEscape, Rate: 1, Width: -0.1},
+		{Kind: "step", Rate: 1, Width: 0.1},
+	} {
+		net, err := NewNetwork([]*Layer{wired(2, 3)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.Layers[0].Neurons[1].Escape = &escape
+		loaded := &Network{}
+		if err := loaded.Load(saved(t, net)); err == nil || !strings.Contains(err.Error(), "neuron 1: escape") {
+			t.Errorf("saved escape %+v: got %v", escape, err)
+		}
+	}
+}
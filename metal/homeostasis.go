@@ -0,0 +1,95 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+)
+
+// Homeostasis keeps a neuron firing near a target rate. A running estimate of
+// the firing rate drives two slow feedback loops: multiplicative scaling of
+// the input weights and adaptation of the threshold. Rates are in spikes per
+// millisecond and time constants in milliseconds; a zero time constant
+// disables its loop.
+//
+// A neuron with Homeostasis set no longer applies the built-in nudges to its
+// bias and adaptive threshold after each step; the adaptive threshold is
+// driven by the rate error instead.
+type Homeostasis struct {
+	TargetRate   float64 `json:"targetRate"`   // desired spikes per ms
+	RateTau      float64 `json:"rateTau"`      // averaging window of the rate estimate
+	ScalingTau   float64 `json:"scalingTau"`   // time constant of synaptic scaling
+	ThresholdTau float64 `json:"thresholdTau"` // time constant of threshold adaptation
+	Rate         float64 `json:"rate"`         // running estimate of the firing rate
+}
+
+// NewHomeostasis returns a homeostasis whose rate estimate starts at target
+func NewHomeostasis(target, rateTau, scalingTau, thresholdTau float64) *Homeostasis {
+	return &Homeostasis{
+		TargetRate:   target,
+		RateTau:      rateTau,
+		ScalingTau:   scalingTau,
+		ThresholdTau: thresholdTau,
+		Rate:         target,
+	}
+}
+
+// Validate checks that the rate and time constants are usable
+func (h *Homeostasis) Validate() error {
+	if h.TargetRate <= 0 || h.TargetRate > 1 {
+		return fmt.Errorf("homeostasis: target rate must be in (0, 1], got %v", h.TargetRate)
+	}
+	if h.RateTau < 1 {
+		return fmt.Errorf("homeostasis: rate time constant must be at least 1 ms, got %v", h.RateTau)
+	}
+	if h.ScalingTau < 0 || h.ThresholdTau < 0 {
+		return fmt.Errorf("homeostasis: time constants must not be negative")
+	}
+	return nil
+}
+
+// SetHomeostasis gives every neuron of the layer its own copy of h, with the
+// rate estimate starting at the target
+func (l *Layer) SetHomeostasis(h Homeostasis) {
+	for i := range l.Neurons {
+		c := h
+		c.Rate = h.TargetRate
+		l.Neurons[i].Homeostasis = &c
+	}
+}
+
+// homeostasis updates the rate estimate with the output of a step of dt
+// milliseconds and applies synaptic scaling and threshold adaptation
+func (n *SpikingNeuron) homeostasis(fired bool, dt float64) {
+	h := n.Homeostasis
+	spike := 0.0
+	if fired {
+		spike = 1 / dt
+	}
+	h.Rate += (spike - h.Rate) * min(dt/h.RateTau, 1)
+
+	// Both loops act on the relative rate error, so their speed does not
+	// depend on the size of the target
+	e := (h.Rate - h.TargetRate) / h.TargetRate
+
+	if h.ScalingTau > 0 {
+		// Excitatory weights grow when the neuron fires too little and
+		// inhibitory weights shrink, both by the same factor
+		factor := math.Exp(-e * dt / h.ScalingTau)
+		for i := range n.Connections {
+			w := n.Connections[i].Weight
+			if w >= 0 {
+				w *= factor
+			} else {
+				w /= factor
+			}
+			n.Connections[i].Weight = w
+			n.clampWeight(&n.Connections[i])
+		}
+	}
+
+	if h.ThresholdTau > 0 {
+		// The effective threshold stays above a tenth of the base threshold
+		n.AdaptiveThreshold += n.Threshold * e * dt / h.ThresholdTau
+		n.AdaptiveThreshold = math.Max(n.AdaptiveThreshold, -0.9*n.Threshold)
+	}
+}
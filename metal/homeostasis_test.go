@@ -0,0 +1,117 @@
+package neuron
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// poissonLayer runs a layer with widely spread input weights on random input
+// spikes and returns the firing rate of every neuron over the last window steps
+func poissonLayer(t *testing.T, h *Homeostasis, steps, window int) []float64 {
+	t.Helper()
+	rng := rand.New(rand.NewSource(3))
+	const inputs, size = 10, 20
+
+	neurons := make([]SpikingNeuron, size)
+	for j := range neurons {
+		n := NewSpikingNeuron(inputs, 1.0, 0.8, 0, 0)
+		// From nearly silent to firing at every step
+		scale := 0.05 + 0.6*float64(j)/size
+		for i := range n.Connections {
+			n.Connections[i].Weight = scale * (0.5 + rng.Float64())
+		}
+		neurons[j] = *n
+	}
+	layer := NewLayer(neurons)
+	if h != nil {
+		layer.SetHomeostasis(*h)
+	}
+	net, err := NewNetwork([]*Layer{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetSeed(5)
+
+	counts := make([]float64, size)
+	input := make([]float64, inputs)
+	for step := 0; step < steps; step++ {
+		for i := range input {
+			input[i] = 0
+			if rng.Float64() < 0.3 {
+				input[i] = 1
+			}
+		}
+		output, err := net.Forward(input, step, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step >= steps-window {
+			for j, s := range output {
+				counts[j] += float64(s)
+			}
+		}
+	}
+	for j := range counts {
+		counts[j] /= float64(window)
+	}
+	return counts
+}
+
+func maxRateError(rates []float64, target float64) float64 {
+	worst := 0.0
+	for _, r := range rates {
+		worst = math.Max(worst, math.Abs(r-target))
+	}
+	return worst
+}
+
+func TestHomeostasisConvergesToTarget(t *testing.T) {
+	const target = 0.1
+	before := maxRateError(poissonLayer(t, nil, 6000, 2000), target)
+	if before < 0.05 {
+		t.Fatalf("rates without homeostasis are already near target (error %.3f); the test proves nothing", before)
+	}
+
+	for _, tc := range []struct {
+		name string
+		h    *Homeostasis
+	}{
+		{"scaling", NewHomeostasis(target, 100, 50, 0)},
+		{"threshold", NewHomeostasis(target, 100, 0, 50)},
+		{"both", NewHomeostasis(target, 100, 100, 100)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rates := poissonLayer(t, tc.h, 6000, 2000)
+			if err := maxRateError(rates, target); err > 0.02 {
+				t.Errorf("rates %v: worst error %.3f from target %v (%.3f without homeostasis)", rates, err, target, before)
+			}
+		})
+	}
+}
+
+func TestHomeostasisRateEstimate(t *testing.T) {
+	n := NewSpikingNeuron(0, 1, 0.5, 0, 0)
+	n.Homeostasis = NewHomeostasis(0.5, 10, 0, 0)
+	for i := 0; i < 200; i++ {
+		n.homeostasis(i%4 == 0, 1)
+	}
+	if math.Abs(n.Homeostasis.Rate-0.25) > 0.05 {
+		t.Errorf("rate estimate %.3f for one spike in four steps", n.Homeostasis.Rate)
+	}
+}
+
+func TestHomeostasisValidate(t *testing.T) {
+	for _, h := range []Homeostasis{
+		{TargetRate: 0, RateTau: 10},
+		{TargetRate: 0.1, RateTau: 0},
+		{TargetRate: 0.1, RateTau: 10, ScalingTau: -1},
+	} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", h)
+		}
+	}
+	if err := NewHomeostasis(0.1, 10, 10, 10).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,173 @@
+package neuron
+
+import "fmt"
+
+// WeightUpdate describes a connection weight changed by learning during a step.
+// Connection indexes the neuron's Connections, or its Recurrent connections
+// when Recurrent is set.
+type WeightUpdate struct {
+	Time       int
+	Layer      int
+	Neuron     int
+	Connection int
+	Recurrent  bool
+	Old, New   float64
+}
+
+// ThresholdChange describes a change of a neuron's effective threshold
+// (Threshold + AdaptiveThreshold) during a step
+type ThresholdChange struct {
+	Time     int
+	Layer    int
+	Neuron   int
+	Old, New float64
+}
+
+// hooks holds the callbacks registered on a network. They run on the
+// goroutine that calls Forward, in registration order.
+type hooks struct {
+	stepStart []func(net *Network, t int)
+	stepEnd   []func(net *Network, t int, output []int)
+	spike     []func(net *Network, e Event)
+	weight    []func(net *Network, u WeightUpdate)
+	threshold []func(net *Network, c ThresholdChange)
+	monitors  []*Monitor
+}
+
+// OnStepStart registers fn to run before the input reaches the first layer
+func (n *Network) OnStepStart(fn func(net *Network, t int)) {
+	n.hooks.stepStart = append(n.hooks.stepStart, fn)
+}
+
+// OnStepEnd registers fn to run after the last layer, with the network output
+func (n *Network) OnStepEnd(fn func(net *Network, t int, output []int)) {
+	n.hooks.stepEnd = append(n.hooks.stepEnd, fn)
+}
+
+// OnSpike registers fn to run for every neuron that fires
+func (n *Network) OnSpike(fn func(net *Network, e Event)) {
+	n.hooks.spike = append(n.hooks.spike, fn)
+}
+
+// OnWeightUpdate registers fn to run for every input or recurrent connection
+// whose weight changed,
+// by learning in Forward, by the constraints or by an optimizer step of
+// BPTTTrainer or EPropTrainer. Updates made outside Forward are reported at the
+// network's Time.
+func (n *Network) OnWeightUpdate(fn func(net *Network, u WeightUpdate)) {
+	n.hooks.weight = append(n.hooks.weight, fn)
+}
+
+// OnThresholdChange registers fn to run for every neuron whose effective
+// threshold changed
+func (n *Network) OnThresholdChange(fn func(net *Network, c ThresholdChange)) {
+	n.hooks.threshold = append(n.hooks.threshold, fn)
+}
+
+// Stop asks Run to end after the current step, e.g. from a hook for early stopping
+func (n *Network) Stop() {
+	n.stopped = true
+}
+
+// Stopped reports whether Stop was called since the last Run started
+func (n *Network) Stopped() bool {
+	return n.stopped
+}
+
+// Run calls Forward for steps timesteps with the input returned by input(t),
+// ending early if a hook calls Stop or Forward fails. It returns the output of
+// every step run.
+func (n *Network) Run(steps int, input func(t int) []float64, learningRate float64) ([][]int, error) {
+	n.stopped = false
+	outputs := make([][]int, 0, steps)
+	for t := 0; t < steps && !n.stopped; t++ {
+		output, err := n.Forward(input(t), t, learningRate)
+		if err != nil {
+			return outputs, fmt.Errorf("step %d: %w", t, err)
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// layerState is a copy of the learnable state of a layer, taken before a step
+// so that weight and threshold hooks can be told what changed
+type layerState struct {
+	weights    [][]float64
+	recurrent  [][]float64
+	thresholds []float64
+}
+
+func (h *hooks) watchesState() bool {
+	return len(h.weight) > 0 || len(h.threshold) > 0
+}
+
+func snapshot(l *Layer) *layerState {
+	s := &layerState{
+		weights:    make([][]float64, len(l.Neurons)),
+		recurrent:  make([][]float64, len(l.Neurons)),
+		thresholds: make([]float64, len(l.Neurons)),
+	}
+	for i := range l.Neurons {
+		n := &l.Neurons[i]
+		s.thresholds[i] = n.Threshold + n.AdaptiveThreshold
+		s.weights[i] = weights(n.Connections)
+		s.recurrent[i] = weights(n.Recurrent)
+	}
+	return s
+}
+
+func weights(connections []Connection) []float64 {
+	w := make([]float64, len(connections))
+	for c := range connections {
+		w[c] = connections[c].Weight
+	}
+	return w
+}
+
+// track runs change, which updates the network outside Forward, and fires the
+// weight and threshold hooks for what it changed, reported at time t
+func (n *Network) track(t int, change func()) {
+	if !n.hooks.watchesState() {
+		change()
+		return
+	}
+	before := make([]*layerState, len(n.Layers))
+	for l, layer := range n.Layers {
+		before[l] = snapshot(layer)
+	}
+	change()
+	for l, layer := range n.Layers {
+		n.dispatchChanges(t, l, layer, before[l])
+	}
+}
+
+func (n *Network) dispatchChanges(t, index int, l *Layer, before *layerState) {
+	updated := func(u WeightUpdate, connections []Connection, old []float64) {
+		for c := range connections {
+			if old[c] == connections[c].Weight {
+				continue
+			}
+			u.Connection, u.Old, u.New = c, old[c], connections[c].Weight
+			for _, fn := range n.hooks.weight {
+				fn(n, u)
+			}
+		}
+	}
+	for i := range l.Neurons {
+		neuron := &l.Neurons[i]
+		u := WeightUpdate{Time: t, Layer: index, Neuron: i}
+		updated(u, neuron.Connections, before.weights[i])
+		u.Recurrent = true
+		updated(u, neuron.Recurrent, before.recurrent[i])
+
+		old, now := before.thresholds[i], neuron.Threshold+neuron.AdaptiveThreshold
+		if old == now {
+			continue
+		}
+		c := ThresholdChange{Time: t, Layer: index, Neuron: i, Old: old, New: now}
+		for _, fn := range n.hooks.threshold {
+			fn(n, c)
+		}
+	}
+}
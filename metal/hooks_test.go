@@ -0,0 +1,132 @@
+package neuron
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestHookOrder(t *testing.T) {
+	n := NewSpikingNeuron(1, 0.5, 0.9, 0, 0)
+	n.Connections[0].Weight = 1
+	layer := NewLayer([]SpikingNeuron{*n})
+	layer.SetNoise(Noise{Kind: NoNoise})
+	net, err := NewNetwork([]*Layer{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	net.OnStepStart(func(_ *Network, t int) { calls = append(calls, "start") })
+	net.OnSpike(func(_ *Network, e Event) { calls = append(calls, "spike") })
+	net.OnStepEnd(func(_ *Network, t int, output []int) { calls = append(calls, "end") })
+	if _, err := net.Forward([]float64{1}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0] != "start" || calls[1] != "spike" || calls[2] != "end" {
+		t.Errorf("hooks ran as %v, want start, spike, end", calls)
+	}
+}
+
+// weightLog collects weight updates and checks each against the weight the
+// connection ends with
+func weightLog(t *testing.T, net *Network) *[]WeightUpdate {
+	var updates []WeightUpdate
+	net.OnWeightUpdate(func(net *Network, u WeightUpdate) {
+		if u.Old == u.New {
+			t.Errorf("update %+v does not change the weight", u)
+		}
+		updates = append(updates, u)
+	})
+	return &updates
+}
+
+func TestWeightHooksFromConstraints(t *testing.T) {
+	net := denseNetwork(t, rand.New(rand.NewSource(6)), 3, 2)
+	net.Layers[0].Constraints = []Constraint{{Kind: L1Norm, Target: 0.1}}
+	updates := weightLog(t, net)
+
+	// No learning: every change comes from the constraint
+	if _, err := net.Forward([]float64{0, 0, 0}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(*updates) != 6 {
+		t.Fatalf("%d weight updates, want one for each of the 6 connections", len(*updates))
+	}
+	for _, u := range *updates {
+		if w := net.Layers[0].Neurons[u.Neuron].Connections[u.Connection].Weight; u.New != w {
+			t.Errorf("update %+v, connection has %v", u, w)
+		}
+	}
+}
+
+func TestWeightHooksFromTrainers(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	net := denseNetwork(t, rng, 4, 2)
+	net.Time = 12
+	updates := weightLog(t, net)
+	samples := separableDataset(rng, 2, 4, 2, 5)
+	if _, err := NewBPTTTrainer(net, FastSigmoid(2), &SGD{LearningRate: 0.5}).TrainBatch(samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(*updates) == 0 {
+		t.Fatal("BPTT step fired no weight hooks")
+	}
+	for _, u := range *updates {
+		if w := net.Layers[u.Layer].Neurons[u.Neuron].Connections[u.Connection].Weight; u.New != w || u.Time != 12 {
+			t.Errorf("update %+v, connection has %v at time 12", u, w)
+		}
+	}
+
+	*updates = nil
+	net.Layers[0].Connect(func(post, pre int) float64 { return 0.1 })
+	net.OnWeightUpdate(func(net *Network, u WeightUpdate) {
+		n := &net.Layers[u.Layer].Neurons[u.Neuron]
+		c := n.Connections
+		if u.Recurrent {
+			c = n.Recurrent
+		}
+		if c[u.Connection].Weight != u.New {
+			t.Errorf("update %+v, connection has %v", u, c[u.Connection].Weight)
+		}
+	})
+	tr, err := NewEPropTrainer(net, 1, 0.5, FastSigmoid(2), &SGD{LearningRate: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for step := 0; step < 5; step++ {
+		if _, _, err := tr.Step([]float64{1, 1, 1, 1}, []float64{3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recurrent := 0
+	for _, u := range *updates {
+		if u.Recurrent {
+			recurrent++
+		}
+	}
+	if len(*updates) == recurrent || recurrent == 0 {
+		t.Errorf("e-prop fired %d weight hooks, %d of them recurrent; want both kinds", len(*updates), recurrent)
+	}
+}
+
+func TestStop(t *testing.T) {
+	net := denseNetwork(t, rand.New(rand.NewSource(8)), 2, 2)
+	net.OnStepEnd(func(net *Network, t int, _ []int) {
+		if t == 3 {
+			net.Stop()
+		}
+	})
+	input := func(int) []float64 { return []float64{1, 0} }
+	outputs, err := net.Run(10, input, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 4 || !net.Stopped() {
+		t.Errorf("ran %d steps (stopped %v), want 4 and stopped", len(outputs), net.Stopped())
+	}
+
+	// Run clears the request, so a new run goes until the hook stops it again
+	if outputs, _ = net.Run(3, input, 0); len(outputs) != 3 || net.Stopped() {
+		t.Errorf("second run: %d steps (stopped %v), want 3 and not stopped", len(outputs), net.Stopped())
+	}
+}
@@ -0,0 +1,134 @@
+package neuron
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"tinybrain/initializer"
+)
+
+// Fields that name connection weights rather than neuron parameters
+const (
+	Weights   = "weights"   // input connection weights
+	Recurrent = "recurrent" // recurrent connection weights
+)
+
+// initFields are the neuron fields an initializer may set, by JSON name.
+// Runtime state such as the membrane potential or the last spike time is
+// left out: it is not a parameter, and the next step would overwrite it.
+var initFields = map[string]func(n *SpikingNeuron, x float64){
+	"threshold":        func(n *SpikingNeuron, x float64) { n.Threshold = x },
+	"decay":            func(n *SpikingNeuron, x float64) { n.Decay = x },
+	"bias":             func(n *SpikingNeuron, x float64) { n.Bias = clamp(x, n.MinBias, n.MaxBias) },
+	"refractoryPeriod": func(n *SpikingNeuron, x float64) { n.RefractoryPeriod = int(math.Floor(x)) },
+	"minWeight":        func(n *SpikingNeuron, x float64) { n.MinWeight = x },
+	"maxWeight":        func(n *SpikingNeuron, x float64) { n.MaxWeight = x },
+	"minBias":          func(n *SpikingNeuron, x float64) { n.MinBias = x },
+	"maxBias":          func(n *SpikingNeuron, x float64) { n.MaxBias = x },
+}
+
+// CheckInit reports whether init is valid and can initialise field
+func CheckInit(field string, init initializer.Initializer) error {
+	if err := init.Validate(); err != nil {
+		return err
+	}
+	if field == Weights || field == Recurrent {
+		return nil
+	}
+	if init.Scaled() {
+		return fmt.Errorf("initializer: %s only applies to weights, not %q", init.Kind, field)
+	}
+	if _, ok := initFields[field]; !ok {
+		return fmt.Errorf("initializer: %q is not a neuron parameter", field)
+	}
+	return nil
+}
+
+// Initialize draws a field of every neuron of the layer from init, drawing
+// from rng or the global source when nil. field is Weights, Recurrent or the
+// JSON name of a parameter: threshold, decay, bias, refractoryPeriod or one
+// of the weight and bias bounds. The refractory period takes the floor of the
+// draw, and weights and the bias are clamped to their bounds. For weights the
+// fan in is the neuron's number of connections and the fan out the size of
+// the layer. Convolutional layers then share the drawn values again.
+func (l *Layer) Initialize(field string, init initializer.Initializer, rng *rand.Rand) error {
+	if err := CheckInit(field, init); err != nil {
+		return err
+	}
+	weights := func(n *SpikingNeuron, connections []Connection) {
+		for k := range connections {
+			c := &connections[k]
+			c.Weight = init.Sample(rng, len(connections), len(l.Neurons))
+			n.clampWeight(c)
+		}
+	}
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+		switch field {
+		case Weights:
+			weights(n, n.Connections)
+		case Recurrent:
+			weights(n, n.Recurrent)
+		default:
+			initFields[field](n, init.Sample(rng, 0, 0))
+		}
+	}
+	l.tie()
+	return nil
+}
+
+// InitRecord documents one initialisation in a network's metadata
+type InitRecord struct {
+	Layer       int                     `json:"layer"`
+	Field       string                  `json:"field"`
+	Initializer initializer.Initializer `json:"initializer"`
+}
+
+// initKey is where the records are kept in the network's metadata
+const initKey = "init"
+
+// Initialize initialises a field of layer l with Layer.Initialize, drawing
+// from the network's random source, and appends an InitRecord to its
+// metadata
+func (n *Network) Initialize(l int, field string, init initializer.Initializer) error {
+	if l < 0 || l >= len(n.Layers) {
+		return fmt.Errorf("initializer: layer %d of %d", l, len(n.Layers))
+	}
+	if err := n.Layers[l].Initialize(field, init, n.Rand()); err != nil {
+		return err
+	}
+	return n.record(InitRecord{Layer: l, Field: field, Initializer: init})
+}
+
+// record appends initialisations to the network's metadata
+func (n *Network) record(add ...InitRecord) error {
+	records, err := n.InitRecords()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(append(records, add...))
+	if err != nil {
+		return err
+	}
+	if n.Metadata == nil {
+		n.Metadata = map[string]json.RawMessage{}
+	}
+	n.Metadata[initKey] = data
+	return nil
+}
+
+// InitRecords returns the initialisations recorded in the network's
+// metadata, in the order they were applied
+func (n *Network) InitRecords() ([]InitRecord, error) {
+	data, ok := n.Metadata[initKey]
+	if !ok {
+		return nil, nil
+	}
+	var records []InitRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("initializer: metadata: %w", err)
+	}
+	return records, nil
+}
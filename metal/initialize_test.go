@@ -0,0 +1,157 @@
+package neuron
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"tinybrain/initializer"
+)
+
+// initialized builds a two-layer network that redraws its output weights
+// and decays with the seeded builder
+func initialized(t *testing.T, seed uint64) *Network {
+	t.Helper()
+	net, err := NewBuilder(4).Seed(seed).
+		Layer("hidden", 8).Done().
+		Layer("out", 3).Init(Weights, initializer.HeNormal(1)).Init("decay", initializer.Uniform(0.8, 0.95)).Done().
+		Project(Input, "hidden", FixedInDegree{K: 2}, initializer.XavierUniform(1)).
+		Project("hidden", "out", AllToAll{}, initializer.Constant(0.2)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func TestInitialize(t *testing.T) {
+	net := initialized(t, 5)
+	out := net.Layers[1]
+	for j, n := range out.Neurons {
+		if n.Decay < 0.8 || n.Decay >= 0.95 {
+			t.Errorf("neuron %d: decay %v outside [0.8, 0.95)", j, n.Decay)
+		}
+		for k, c := range n.Connections {
+			if c.Weight == 0.2 {
+				t.Errorf("neuron %d connection %d: weight not redrawn", j, k)
+			}
+			if lo, hi := n.WeightBounds(c); c.Weight < lo || c.Weight > hi {
+				t.Errorf("neuron %d connection %d: weight %v outside [%v, %v]", j, k, c.Weight, lo, hi)
+			}
+		}
+	}
+	// Xavier over 2 inputs onto 8 neurons: |w| below √(6/10)
+	for j, n := range net.Layers[0].Neurons {
+		for k, c := range n.Connections {
+			if math.Abs(c.Weight) > math.Sqrt(0.6) {
+				t.Errorf("hidden neuron %d connection %d: weight %v beyond the Xavier limit", j, k, c.Weight)
+			}
+		}
+	}
+
+	layer := NewLayer([]SpikingNeuron{*NewSpikingNeuron(2, 1, 0.9, 0, 0)})
+	if err := layer.Initialize("refractoryPeriod", initializer.Constant(2.7), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := layer.Neurons[0].RefractoryPeriod; got != 2 {
+		t.Errorf("refractory period %d from a draw of 2.7, want 2", got)
+	}
+	if err := layer.Initialize("bias", initializer.Constant(5), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := layer.Neurons[0].Bias; got != layer.Neurons[0].MaxBias {
+		t.Errorf("bias %v not clamped to %v", got, layer.Neurons[0].MaxBias)
+	}
+}
+
+func TestInitializeRejects(t *testing.T) {
+	layer := NewLayer([]SpikingNeuron{*NewSpikingNeuron(2, 1, 0.9, 0, 0)})
+	for _, tc := range []struct {
+		field string
+		init  initializer.Initializer
+	}{
+		{"membranePotential", initializer.Constant(1)}, // runtime state
+		{"lastSpikeTime", initializer.Constant(1)},
+		{"refractoryTimer", initializer.Constant(1)},
+		{"type", initializer.Constant(1)},
+		{"threshold", initializer.HeNormal(1)}, // scaled kinds only apply to weights
+		{"threshold", initializer.Uniform(1, 0)},
+		{Weights, initializer.Initializer{Kind: "beta"}},
+	} {
+		before := layer.Neurons[0]
+		if err := layer.Initialize(tc.field, tc.init, nil); err == nil {
+			t.Errorf("%s from %+v: expected an error", tc.field, tc.init)
+		}
+		if !reflect.DeepEqual(before, layer.Neurons[0]) {
+			t.Errorf("%s from %+v: rejected initializer changed the neuron", tc.field, tc.init)
+		}
+	}
+	if _, err := NewBuilder(1).Layer("out", 1).Init("membranePotential", initializer.Constant(1)).Done().
+		Project(Input, "out", AllToAll{}, initializer.Constant(1)).Build(); err == nil {
+		t.Error("builder: expected an error for a runtime field")
+	}
+	net, err := NewNetwork([]*Layer{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := net.Initialize(1, "threshold", initializer.Constant(1)); err == nil {
+		t.Error("layer 1 of 1: expected an error")
+	}
+}
+
+func TestInitRecordsRoundTrip(t *testing.T) {
+	net := initialized(t, 5)
+	if err := net.Initialize(0, "threshold", initializer.LogNormal(0, 0.1)); err != nil {
+		t.Fatal(err)
+	}
+	defaults := func(l int) []InitRecord {
+		return []InitRecord{
+			{Layer: l, Field: "threshold", Initializer: initializer.Constant(1)},
+			{Layer: l, Field: "decay", Initializer: initializer.Constant(0.9)},
+			{Layer: l, Field: "bias", Initializer: initializer.Constant(0)},
+			{Layer: l, Field: "refractoryPeriod", Initializer: initializer.Constant(0)},
+		}
+	}
+	want := append(defaults(0), InitRecord{Layer: 0, Field: Weights, Initializer: initializer.XavierUniform(1)})
+	want = append(want, defaults(1)...)
+	want = append(want, []InitRecord{
+		{Layer: 1, Field: Weights, Initializer: initializer.Constant(0.2)},
+		{Layer: 1, Field: Weights, Initializer: initializer.HeNormal(1)},
+		{Layer: 1, Field: "decay", Initializer: initializer.Uniform(0.8, 0.95)},
+		{Layer: 0, Field: "threshold", Initializer: initializer.LogNormal(0, 0.1)},
+	}...)
+	records, err := net.InitRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records %+v, want %+v", records, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "net.json")
+	if err := net.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Network{}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	records, err = loaded.InitRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("loaded records %+v, want %+v", records, want)
+	}
+}
+
+func TestInitializeIsReproducible(t *testing.T) {
+	a, b, c := initialized(t, 5), initialized(t, 5), initialized(t, 6)
+	if !reflect.DeepEqual(a.Layers, b.Layers) {
+		t.Error("same seed built different networks")
+	}
+	if reflect.DeepEqual(a.Layers, c.Layers) {
+		t.Error("different seeds built the same network")
+	}
+}
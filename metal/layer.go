@@ -1,25 +1,111 @@
 package neuron
 
-import "sync"
+import (
+	"math/rand/v2"
+	"sync"
+)
 
 type Layer struct {
-	Neurons []SpikingNeuron `json:"neurons"`
+	Neurons     []SpikingNeuron `json:"neurons"`
+	Constraints []Constraint    `json:"constraints,omitempty"` // applied to every neuron after each step
+	Rules       *DaleRules      `json:"rules,omitempty"`       // learning rules of typed connections; nil uses DefaultDaleRules
+	Structural  *Structural     `json:"structural,omitempty"`  // prunes and grows synapses after each step
+	Inputs      int             `json:"inputs,omitempty"`      // input width, recorded for sparse layers
+	Conv        *Convolution    `json:"conv,omitempty"`        // shares weights across the positions of each channel
+	Pool        *Pooling        `json:"pool,omitempty"`        // replaces the neurons' dynamics by max pooling
 }
 
 func NewLayer(neurons []SpikingNeuron) *Layer {
 	return &Layer{Neurons: neurons}
 }
 
-func (l *Layer) Forward(inputs []float64, currentTime int, learningRate float64) []int {
+// Forward runs the layer for one step of DefaultDT. It fails without
+// changing the layer when the input does not fit its wiring; as no network
+// validates a layer used on its own, the wiring is checked on every call.
+func (l *Layer) Forward(inputs []float64, currentTime int, learningRate float64) ([]int, error) {
+	if err := l.check(len(inputs)); err != nil {
+		return nil, err
+	}
+	return l.forward(inputs, currentTime, learningRate, DefaultDT, nil), nil
+}
+
+// forward runs a step of dt milliseconds over inputs that fit the layer's
+// wiring, as checked by Validate or Forward. It draws
+// the noise and firing of every neuron from rng (or the global source when rng is nil)
+// before running them in parallel, so a seeded network is reproducible
+// regardless of goroutine scheduling
+func (l *Layer) forward(inputs []float64, currentTime int, learningRate, dt float64, rng *rand.Rand) []int {
+	if l.Pool != nil {
+		return l.pool(inputs, currentTime)
+	}
+	noise := make([]float64, len(l.Neurons))
+	escape := make([]float64, len(l.Neurons))
+	for i := range noise {
+		noise[i] = l.Neurons[i].noise(rng, dt)
+		// Only stochastic neurons draw, so deterministic networks keep
+		// their random sequence
+		if l.Neurons[i].Escape != nil {
+			escape[i] = uniform(rng)
+		}
+	}
+
+	recurrent := l.previousSpikes()
+	soft := l.softBounds()
+
 	spikes := make([]int, len(l.Neurons))
 	var wg sync.WaitGroup
 	for i := range l.Neurons {
 		wg.Add(1)
 		go func(i int) {
-			spikes[i] = l.Neurons[i].Forward(inputs, currentTime, learningRate)
+			n := &l.Neurons[i]
+			var old []float64
+			if soft {
+				old = n.weights()
+			}
+			spikes[i] = n.forward(inputs, recurrent, currentTime, learningRate, dt, noise[i], escape[i], l.Rules)
+			l.constrain(n, old)
 			wg.Done()
 		}(i)
 	}
 	wg.Wait()
+	l.tie()
+	return spikes
+}
+
+// Connect gives every neuron a recurrent connection from every neuron of the
+// layer, including itself, with the weight returned by weight. Recurrent
+// connections carry the spikes of the previous step.
+func (l *Layer) Connect(weight func(post, pre int) float64) {
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+		n.Recurrent = make([]Connection, len(l.Neurons))
+		for i := range n.Recurrent {
+			n.Recurrent[i] = Connection{Weight: weight(j, i), LastPreSpike: -100}
+		}
+	}
+}
+
+// Recurrent reports whether any neuron of the layer has recurrent connections
+func (l *Layer) Recurrent() bool {
+	for _, n := range l.Neurons {
+		if len(n.Recurrent) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// previousSpikes returns the Fired flags of the last step as inputs for the
+// recurrent connections, or nil when the layer has none
+func (l *Layer) previousSpikes() []float64 {
+	if !l.Recurrent() {
+		return nil
+	}
+	spikes := make([]float64, len(l.Neurons))
+	for i, n := range l.Neurons {
+		if n.Fired {
+			spikes[i] = 1
+		}
+	}
 	return spikes
 }
@@ -0,0 +1,345 @@
+package neuron
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Variable names a piece of state that a probe can sample
+type Variable int
+
+const (
+	Potential Variable = iota
+	Threshold
+	AdaptiveThreshold
+	Weight
+	Spike
+	Utilization // short-term plasticity u of a connection
+	Resources   // short-term plasticity x of a connection
+	Efficacy    // effective weight of a connection after short-term plasticity
+	ExcitatoryConductance
+	InhibitoryConductance
+	Dendrite     // potential of each dendrite, with the dendrite index as connection
+	NoiseCurrent // Ornstein–Uhlenbeck background current of the membrane noise
+)
+
+func (v Variable) String() string {
+	switch v {
+	case Potential:
+		return "potential"
+	case Threshold:
+		return "threshold"
+	case AdaptiveThreshold:
+		return "adaptiveThreshold"
+	case Weight:
+		return "weight"
+	case Spike:
+		return "spike"
+	case Utilization:
+		return "utilization"
+	case Resources:
+		return "resources"
+	case Efficacy:
+		return "efficacy"
+	case ExcitatoryConductance:
+		return "conductanceE"
+	case InhibitoryConductance:
+		return "conductanceI"
+	case Dendrite:
+		return "dendrite"
+	case NoiseCurrent:
+		return "noiseCurrent"
+	}
+	return "variable(" + strconv.Itoa(int(v)) + ")"
+}
+
+// Sample is one recorded value. Connection is -1 for neuron variables.
+type Sample struct {
+	Time       int      `json:"t"`
+	Probe      string   `json:"probe"`
+	Variable   Variable `json:"-"`
+	Layer      int      `json:"layer"`
+	Neuron     int      `json:"neuron"`
+	Connection int      `json:"connection"`
+	Value      float64  `json:"value"`
+}
+
+// Probe samples one variable from a selection of neurons (or their
+// connections, for connection variables) of a single layer. Connections
+// without short-term plasticity report a utilization and resources of 1.
+type Probe struct {
+	Name        string
+	Variable    Variable
+	Layer       int
+	Neurons     []int // nil selects every neuron in the layer
+	Connections []int // connection variables only; nil selects every connection
+	Interval    int   // sample every Interval steps; 0 means every step
+	MaxSamples  int   // keep at most this many samples in memory; 0 means no limit
+	Samples     []Sample
+}
+
+// Last returns the most recent sample, if any
+func (p *Probe) Last() (Sample, bool) {
+	if len(p.Samples) == 0 {
+		return Sample{}, false
+	}
+	return p.Samples[len(p.Samples)-1], true
+}
+
+func (p *Probe) collect(t int, net *Network) ([]Sample, error) {
+	if p.Layer < 0 || p.Layer >= len(net.Layers) {
+		return nil, fmt.Errorf("probe %q: layer %d out of range", p.Name, p.Layer)
+	}
+	layer := net.Layers[p.Layer]
+
+	neurons := p.Neurons
+	if neurons == nil {
+		neurons = indices(len(layer.Neurons))
+	}
+
+	var samples []Sample
+	for _, i := range neurons {
+		if i < 0 || i >= len(layer.Neurons) {
+			return nil, fmt.Errorf("probe %q: neuron %d out of range", p.Name, i)
+		}
+		n := &layer.Neurons[i]
+		s := Sample{Time: t, Probe: p.Name, Variable: p.Variable, Layer: p.Layer, Neuron: i, Connection: -1}
+
+		switch p.Variable {
+		case Potential:
+			s.Value = n.MembranePotential
+		case Threshold:
+			s.Value = n.Threshold
+		case AdaptiveThreshold:
+			s.Value = n.AdaptiveThreshold
+		case Spike:
+			if n.Fired {
+				s.Value = 1
+			}
+		case ExcitatoryConductance:
+			if n.Conductance != nil {
+				s.Value = n.Conductance.GE
+			}
+		case InhibitoryConductance:
+			if n.Conductance != nil {
+				s.Value = n.Conductance.GI
+			}
+		case NoiseCurrent:
+			if n.Noise != nil {
+				s.Value = n.Noise.Current
+			}
+		case Dendrite:
+			for d := range n.Dendrites {
+				s.Connection = d
+				s.Value = n.Dendrites[d].Potential
+				samples = append(samples, s)
+			}
+			continue
+		case Weight, Utilization, Resources, Efficacy:
+			connections := p.Connections
+			if connections == nil {
+				connections = indices(len(n.Connections))
+			}
+			for _, c := range connections {
+				if c < 0 || c >= len(n.Connections) {
+					return nil, fmt.Errorf("probe %q: connection %d out of range", p.Name, c)
+				}
+				s.Connection = c
+				s.Value = connectionValue(&n.Connections[c], p.Variable)
+				samples = append(samples, s)
+			}
+			continue
+		default:
+			return nil, fmt.Errorf("probe %q: unknown variable %v", p.Name, p.Variable)
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+func connectionValue(c *Connection, v Variable) float64 {
+	switch v {
+	case Utilization:
+		if c.STP != nil {
+			return c.STP.Utilization
+		}
+		return 1
+	case Resources:
+		if c.STP != nil {
+			return c.STP.Resources
+		}
+		return 1
+	case Efficacy:
+		if c.STP != nil {
+			return c.Weight * c.STP.Efficacy()
+		}
+	}
+	return c.Weight
+}
+
+func indices(n int) []int {
+	result := make([]int, n)
+	for i := range result {
+		result[i] = i
+	}
+	return result
+}
+
+// Sink receives samples as they are recorded
+type Sink interface {
+	WriteSample(s Sample) error
+	Flush() error
+}
+
+// Monitor owns a set of probes and forwards their samples to sinks.
+// Attach it to a network to have it sampled after every Forward step.
+type Monitor struct {
+	Probes []*Probe
+	sinks  []Sink
+	err    error
+}
+
+func NewMonitor(sinks ...Sink) *Monitor {
+	return &Monitor{sinks: sinks}
+}
+
+// Add registers a probe and returns it so its buffer can be read later
+func (m *Monitor) Add(p *Probe) *Probe {
+	if p.Name == "" {
+		p.Name = fmt.Sprintf("L%d.%s", p.Layer, p.Variable)
+	}
+	m.Probes = append(m.Probes, p)
+	return p
+}
+
+// Sample records every probe that is due at time t
+func (m *Monitor) Sample(t int, net *Network) error {
+	if m.err != nil {
+		return m.err
+	}
+	for _, p := range m.Probes {
+		if p.Interval > 1 && t%p.Interval != 0 {
+			continue
+		}
+		samples, err := p.collect(t, net)
+		if err != nil {
+			m.err = err
+			return err
+		}
+
+		p.Samples = append(p.Samples, samples...)
+		if p.MaxSamples > 0 && len(p.Samples) > p.MaxSamples {
+			p.Samples = p.Samples[len(p.Samples)-p.MaxSamples:]
+		}
+
+		for _, sink := range m.sinks {
+			for _, s := range samples {
+				if err := sink.WriteSample(s); err != nil {
+					m.err = err
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Flush flushes every sink and reports the first error seen while sampling
+func (m *Monitor) Flush() error {
+	for _, sink := range m.sinks {
+		if err := sink.Flush(); err != nil && m.err == nil {
+			m.err = err
+		}
+	}
+	return m.err
+}
+
+// Attach samples the monitor at the end of every Forward step, after the step
+// end hooks. When sampling fails Forward returns the error along with the
+// output of the step, which has already run, and so does every later step.
+func (n *Network) Attach(m *Monitor) {
+	n.hooks.monitors = append(n.hooks.monitors, m)
+}
+
+// CSVSink writes samples as rows of t,probe,variable,layer,neuron,connection,value
+type CSVSink struct {
+	w      *csv.Writer
+	header bool
+}
+
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{w: csv.NewWriter(w)}
+}
+
+func (c *CSVSink) WriteSample(s Sample) error {
+	if !c.header {
+		c.header = true
+		if err := c.w.Write([]string{"t", "probe", "variable", "layer", "neuron", "connection", "value"}); err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(s.Time),
+		s.Probe,
+		s.Variable.String(),
+		strconv.Itoa(s.Layer),
+		strconv.Itoa(s.Neuron),
+		strconv.Itoa(s.Connection),
+		strconv.FormatFloat(s.Value, 'f', -1, 64),
+	})
+}
+
+func (c *CSVSink) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// JSONLSink writes one JSON object per sample
+type JSONLSink struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	buf := bufio.NewWriter(w)
+	return &JSONLSink{w: buf, enc: json.NewEncoder(buf)}
+}
+
+func (j *JSONLSink) WriteSample(s Sample) error {
+	return j.enc.Encode(struct {
+		Sample
+		Variable string `json:"variable"`
+	}{s, s.Variable.String()})
+}
+
+func (j *JSONLSink) Flush() error {
+	return j.w.Flush()
+}
+
+// BinarySink writes fixed 28-byte little-endian records: int32 t, variable,
+// layer, neuron and connection followed by a float64 value. Probe names are
+// not stored.
+type BinarySink struct {
+	w *bufio.Writer
+}
+
+func NewBinarySink(w io.Writer) *BinarySink {
+	return &BinarySink{w: bufio.NewWriter(w)}
+}
+
+func (b *BinarySink) WriteSample(s Sample) error {
+	record := struct {
+		Time, Variable, Layer, Neuron, Connection int32
+		Value                                     float64
+	}{int32(s.Time), int32(s.Variable), int32(s.Layer), int32(s.Neuron), int32(s.Connection), s.Value}
+	return binary.Write(b.w, binary.LittleEndian, record)
+}
+
+func (b *BinarySink) Flush() error {
+	return b.w.Flush()
+}
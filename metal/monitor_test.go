@@ -0,0 +1,117 @@
+package neuron
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// monitoredNetwork has one layer of two neurons reading two inputs
+func monitoredNetwork(t *testing.T) *Network {
+	t.Helper()
+	neurons := []SpikingNeuron{*NewSpikingNeuron(2, 1, 0.9, 0, 0), *NewSpikingNeuron(2, 1, 0.9, 0, 0)}
+	for j := range neurons {
+		for i := range neurons[j].Connections {
+			neurons[j].Connections[i].Weight = 0.3 * float64(j+i+1)
+		}
+	}
+	layer := NewLayer(neurons)
+	layer.SetNoise(Noise{Kind: NoNoise})
+	net, err := NewNetwork([]*Layer{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func TestProbeSampling(t *testing.T) {
+	net := monitoredNetwork(t)
+	m := NewMonitor()
+	potential := m.Add(&Probe{Variable: Potential, Neurons: []int{1}, Interval: 2})
+	weights := m.Add(&Probe{Name: "w", Variable: Weight, Connections: []int{1}, MaxSamples: 3})
+	net.Attach(m)
+
+	for step := 0; step < 4; step++ {
+		if _, err := net.Forward([]float64{1, 0}, step, 0); err != nil {
+			t.Fatal(err)
+		}
+		if s, ok := potential.Last(); ok && s.Time == step && s.Value != net.Layers[0].Neurons[1].MembranePotential {
+			t.Errorf("step %d: sampled potential %v, neuron has %v", step, s.Value, net.Layers[0].Neurons[1].MembranePotential)
+		}
+	}
+
+	if potential.Name != "L0.potential" {
+		t.Errorf("default name %q, want L0.potential", potential.Name)
+	}
+	if len(potential.Samples) != 2 || potential.Samples[0].Time != 0 || potential.Samples[1].Time != 2 {
+		t.Errorf("potential sampled at %+v, want steps 0 and 2", potential.Samples)
+	}
+	for _, s := range potential.Samples {
+		if s.Neuron != 1 || s.Connection != -1 {
+			t.Errorf("potential sample %+v, want neuron 1 and no connection", s)
+		}
+	}
+	// Two neurons with one selected connection each, four steps, three kept
+	if len(weights.Samples) != 3 {
+		t.Fatalf("%d weight samples, want the last 3", len(weights.Samples))
+	}
+	last := weights.Samples[2]
+	if w := net.Layers[0].Neurons[1].Connections[1].Weight; last.Time != 3 || last.Neuron != 1 || last.Connection != 1 || last.Value != w {
+		t.Errorf("last weight sample %+v, want neuron 1 connection 1 of %v at step 3", last, w)
+	}
+}
+
+func TestMonitorSinks(t *testing.T) {
+	net := monitoredNetwork(t)
+	var csvOut, jsonOut, binOut bytes.Buffer
+	m := NewMonitor(NewCSVSink(&csvOut), NewJSONLSink(&jsonOut), NewBinarySink(&binOut))
+	m.Add(&Probe{Name: "v", Variable: Potential, Neurons: []int{0}})
+	net.Attach(m)
+	for step := 0; step < 2; step++ {
+		if _, err := net.Forward([]float64{1, 1}, step, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(rows) != 3 || rows[0] != "t,probe,variable,layer,neuron,connection,value" || !strings.HasPrefix(rows[2], "1,v,potential,0,0,-1,") {
+		t.Errorf("csv:\n%s", csvOut.String())
+	}
+	lines := strings.Split(strings.TrimSpace(jsonOut.String()), "\n")
+	var decoded struct {
+		Time     int    `json:"t"`
+		Variable string `json:"variable"`
+	}
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &decoded) != nil || decoded.Time != 1 || decoded.Variable != "potential" {
+		t.Errorf("json lines:\n%s", jsonOut.String())
+	}
+	if binOut.Len() != 2*28 {
+		t.Errorf("binary sink wrote %d bytes, want two 28-byte records", binOut.Len())
+	}
+}
+
+func TestMonitorErrorStopsRun(t *testing.T) {
+	net := monitoredNetwork(t)
+	m := NewMonitor()
+	m.Add(&Probe{Variable: Potential, Layer: 3})
+	net.Attach(m)
+
+	output, err := net.Forward([]float64{1, 1}, 0, 0)
+	if err == nil {
+		t.Fatal("probe of a missing layer: expected an error from Forward")
+	}
+	if len(output) != 2 {
+		t.Errorf("output %v: the step has run and its output should be returned", output)
+	}
+	outputs, err := net.Run(5, func(int) []float64 { return []float64{1, 1} }, 0)
+	if err == nil || len(outputs) != 0 {
+		t.Errorf("Run returned %d steps and error %v, want it to fail on the first", len(outputs), err)
+	}
+	if m.Flush() == nil {
+		t.Error("Flush should report the sampling error again")
+	}
+}
@@ -1,20 +1,138 @@
 package neuron
 
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+)
+
 // Spiking neural network
 type Network struct {
 	Layers []*Layer `json:"layers"`
 	Time   int      `json:"time"`
+	Seed   uint64   `json:"seed,omitempty"` // Seeds the membrane noise; 0 uses the global source
+	DT     float64  `json:"dt,omitempty"`   // Milliseconds per step; 0 means DefaultDT
+
+	// Metadata documents the network, e.g. how it was initialised. It is
+	// saved with the network and not used by the simulation.
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty"`
+
+	rng     *rand.Rand
+	hooks   hooks
+	stopped bool
+	valid   bool // the wiring passed Validate
+}
+
+// NewNetwork returns a network of the layers, or the error Validate reports
+func NewNetwork(layers []*Layer) (*Network, error) {
+	net := &Network{Layers: layers, Time: 0}
+	if err := net.Validate(); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+// SetSeed makes the network's noise reproducible: two networks with the same
+// state and seed produce the same spikes for the same inputs
+func (n *Network) SetSeed(seed uint64) {
+	n.Seed = seed
+	n.rng = nil
 }
 
-func NewNetwork(layers []*Layer) *Network {
-	return &Network{Layers: layers, Time: 0}
+// Rand returns the network's random source, or nil when it has no seed
+func (n *Network) Rand() *rand.Rand {
+	if n.rng == nil && n.Seed != 0 {
+		n.rng = rand.New(rand.NewPCG(n.Seed, n.Seed))
+	}
+	return n.rng
+}
+
+// Clone returns a deep copy of the saved network state, without hooks. The
+// copy's random source restarts from the seed. It fails like Save does when
+// the state holds NaN or infinite values.
+func (n *Network) Clone() (*Network, error) {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	clone := &Network{}
+	return clone, json.Unmarshal(data, clone)
+}
+
+// inputType returns the presynaptic type of each input of layer l: the
+// neuron types of the layer below, or for the first layer the type its
+// existing connections were given
+func (n *Network) inputType(l int) func(input int) NeuronType {
+	if l > 0 {
+		below := n.Layers[l-1]
+		return func(input int) NeuronType { return below.Neurons[input].Type }
+	}
+	var t NeuronType
+	for _, neuron := range n.Layers[0].Neurons {
+		if len(neuron.Connections) > 0 {
+			t = neuron.Connections[0].Pre
+			break
+		}
+	}
+	return func(int) NeuronType { return t }
 }
 
-func (n *Network) Forward(input []float64, currentTime int, learningRate float64) []int {
-	for _, layer := range n.Layers {
-		input = floatSlice(layer.Forward(input, currentTime, learningRate))
+// Forward runs the network for one step and returns the spikes of the last
+// layer. A network that was not validated, such as one assembled by hand or
+// decoded without Load, is validated before its first step; after changing
+// the wiring of a network, call Validate again. An invalid network or an
+// input of the wrong width is reported before anything runs.
+func (n *Network) Forward(input []float64, currentTime int, learningRate float64) ([]int, error) {
+	if !n.valid {
+		if err := n.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if size := n.InputSize(); len(input) != size {
+		return nil, fmt.Errorf("input has %d values, network reads %d", len(input), size)
+	}
+	dt := n.TimeStep()
+	for _, fn := range n.hooks.stepStart {
+		fn(n, currentTime)
+	}
+
+	for l, layer := range n.Layers {
+		var before *layerState
+		if n.hooks.watchesState() {
+			before = snapshot(layer)
+		}
+
+		width := len(input)
+		input = floatSlice(layer.forward(input, currentTime, learningRate, dt, n.Rand()))
+
+		if before != nil {
+			n.dispatchChanges(currentTime, l, layer, before)
+		}
+		if layer.Structural != nil {
+			layer.restructure(width, dt, n.Rand(), n.inputType(l))
+		}
+		if len(n.hooks.spike) > 0 {
+			for i, v := range input {
+				if v < 1 {
+					continue
+				}
+				for _, fn := range n.hooks.spike {
+					fn(n, Event{Time: currentTime, Layer: l, Neuron: i})
+				}
+			}
+		}
+	}
+
+	output := intSlice(input)
+	for _, fn := range n.hooks.stepEnd {
+		fn(n, currentTime, output)
+	}
+	for _, m := range n.hooks.monitors {
+		if err := m.Sample(currentTime, n); err != nil {
+			return output, fmt.Errorf("monitor: %w", err)
+		}
 	}
-	return intSlice(input)
+	return output, nil
 }
 
 func floatSlice(inputs []int) []float64 {
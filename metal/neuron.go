@@ -1,31 +1,76 @@
 package neuron
 
-import (
-	"math"
-	"math/rand/v2"
-)
+import "math"
 
 type Connection struct {
 	Weight        float64 `json:"weight"`
 	LastPreSpike  int     `json:"lastPreSpike"`  // Last time pre-synaptic neuron fired
 	LastPostSpike int     `json:"lastPostSpike"` // Last time this neuron fired
+
+	// STP, when set, scales the weight by short-term facilitation and
+	// depression
+	STP *ShortTermPlasticity `json:"stp,omitempty"`
+	// Pre is the type of the presynaptic neuron, which fixes the sign of
+	// the weight under Dale's law
+	Pre NeuronType `json:"pre,omitempty"`
+	// Target is the compartment the connection drives: 0 for the soma, k
+	// for the neuron's Dendrites[k-1]
+	Target int `json:"target,omitempty"`
+	// Source is the index of the input the connection reads in a sparse
+	// neuron; dense neurons read input i through Connections[i]
+	Source int `json:"source,omitempty"`
+	// WeakFor counts the steps the weight has stayed below the pruning
+	// threshold of structural plasticity
+	WeakFor int `json:"weakFor,omitempty"`
 }
 
 type SpikingNeuron struct {
 	MembranePotential float64      `json:"membranePotential"`
 	Threshold         float64      `json:"threshold"`         // Base spike threshold
 	AdaptiveThreshold float64      `json:"adaptiveThreshold"` // Dynamic threshold adjustment
-	Decay             float64      `json:"decay"`             // Membrane potential decay rate
-	Bias              float64      `json:"bias"`              // Constant input bias
+	Decay             float64      `json:"decay"`             // Fraction of membrane potential kept per ms
+	Bias              float64      `json:"bias"`              // Constant input per ms
 	Connections       []Connection `json:"connections"`       // Input connections
-	RefractoryPeriod  int          `json:"refractoryPeriod"`  // Steps before next allowed spike
-	RefractoryTimer   int          `json:"refractoryTimer"`   // Current refractory countdown
+	RefractoryPeriod  int          `json:"refractoryPeriod"`  // Milliseconds before next allowed spike
+	RefractoryTimer   int          `json:"refractoryTimer"`   // Current refractory countdown, in steps
 	LastSpikeTime     int          `json:"lastSpikeTime"`     // Last spike timestep
 	MinWeight         float64      `json:"minWeight"`         // Minimum connection weight
 	MaxWeight         float64      `json:"maxWeight"`         // Maximum connection weight
 	Fired             bool         `json:"fired"`
 	MinBias           float64      `json:"minBias"`
 	MaxBias           float64      `json:"maxBias"`
+
+	// Type makes the neuron excitatory or inhibitory under Dale's law
+	Type NeuronType `json:"type,omitempty"`
+
+	// Conductance, when set, makes the synapses conductance-based
+	Conductance *Conductance `json:"conductance,omitempty"`
+
+	// Dendrites are compartments coupled to the soma; connections reach
+	// them through their Target
+	Dendrites []Compartment `json:"dendrites,omitempty"`
+
+	// Sparse neurons list only the connections they have, each naming its
+	// input by Source
+	Sparse bool `json:"sparse,omitempty"`
+
+	// Homeostasis, when set, replaces the built-in threshold and bias
+	// nudges with rate-based homeostatic control
+	Homeostasis *Homeostasis `json:"homeostasis,omitempty"`
+
+	// Recurrent connections carry the previous step's spikes of the neuron's
+	// own layer, one per neuron of the layer
+	Recurrent []Connection `json:"recurrent,omitempty"`
+
+	// Noise, when set, replaces DefaultNoise as the membrane noise
+	Noise *Noise `json:"noise,omitempty"`
+
+	// Escape, when set, makes firing stochastic around the threshold
+	Escape *Escape `json:"escape,omitempty"`
+
+	// Scratch buffers reused from step to step
+	gathered  []float64 // inputs of sparse connections
+	dendritic []float64 // drive of each dendrite
 }
 
 func NewSpikingNeuron(
@@ -55,44 +100,105 @@ func NewSpikingNeuron(
 	}
 }
 
-func (n *SpikingNeuron) Forward(inputs []float64, currentTime int, learningRate float64) int {
-	if len(inputs) != len(n.Connections) {
-		panic("input/connection size mismatch")
+// Forward runs the neuron on its own for one step of DefaultDT. It fails
+// without changing the neuron when the input does not fit its connections; a
+// lone neuron cannot have recurrent connections.
+func (n *SpikingNeuron) Forward(inputs []float64, currentTime int, learningRate float64) (int, error) {
+	if err := n.check(len(inputs), 0); err != nil {
+		return 0, err
+	}
+	return n.forward(inputs, nil, currentTime, learningRate, DefaultDT, n.noise(nil, DefaultDT), uniform(nil), nil), nil
+}
+
+// forward runs one step of dt milliseconds; recurrent holds the layer's
+// spikes of the previous step, noise is the membrane noise of the step and
+// escape a uniform draw in [0, 1) for stochastic firing, both drawn by the
+// caller so that it controls where randomness comes from. rules are the
+// learning rules of typed connections; nil uses DefaultDaleRules. The caller
+// has checked the wiring.
+func (n *SpikingNeuron) forward(inputs, recurrent []float64, currentTime int, learningRate, dt, noise, escape float64, rules *DaleRules) int {
+	// From here on inputs[i] is the input of Connections[i]
+	inputs = n.presynaptic(inputs)
+	if len(n.dendritic) != len(n.Dendrites) {
+		n.dendritic = make([]float64, len(n.Dendrites))
 	}
+	dendritic := n.dendritic
+	clear(dendritic)
+	decay := kept(n.Decay, dt)
 
 	// Refractory period handling
 	if n.RefractoryTimer > 0 {
 		n.Fired = false
 		n.RefractoryTimer--
-		n.MembranePotential *= n.Decay // Still decay during refractory
+		n.MembranePotential *= decay // Still decay during refractory
+		// Presynaptic resources are used up whether or not the neuron listens
+		for i, input := range inputs {
+			if stp := n.Connections[i].STP; stp != nil {
+				stp.step(input, dt)
+			}
+		}
+		if n.Conductance != nil {
+			n.Conductance.update(0, 0, dt) // Closed to new input, like the membrane
+		}
+		n.dendrites(dendritic, dt)
+		if n.Homeostasis != nil {
+			n.homeostasis(false, dt)
+		}
 		return 0
 	}
 
 	// Decay and integrate inputs
-	n.MembranePotential *= n.Decay
+	n.Fired = false
+	n.MembranePotential *= decay
 	weightedSum := 0.0
+	excitation, inhibition := 0.0, 0.0 // Only used by conductance synapses
 	for i, input := range inputs {
-		weightedSum += input * n.Connections[i].Weight
+		weight := n.Connections[i].Weight
+		if stp := n.Connections[i].STP; stp != nil {
+			weight *= stp.step(input, dt)
+		}
 		n.Connections[i].LastPreSpike = currentTime
+		if target := n.Connections[i].Target; target != 0 {
+			addDrive(dendritic, target, input*weight)
+			continue
+		}
+		weightedSum += input * weight
+		excitation, inhibition = split(input*weight, excitation, inhibition)
+	}
+	for i := range n.Recurrent {
+		if target := n.Recurrent[i].Target; target != 0 {
+			addDrive(dendritic, target, recurrent[i]*n.Recurrent[i].Weight)
+			continue
+		}
+		weightedSum += recurrent[i] * n.Recurrent[i].Weight
+		excitation, inhibition = split(recurrent[i]*n.Recurrent[i].Weight, excitation, inhibition)
+	}
+	if n.Conductance != nil {
+		n.Conductance.update(excitation, inhibition, dt)
+		n.MembranePotential = n.Conductance.drive(n.MembranePotential, dt) + n.Bias*dt
+	} else {
+		n.MembranePotential += weightedSum + n.Bias*dt
 	}
-	n.MembranePotential += weightedSum + n.Bias
+	n.MembranePotential += n.dendrites(dendritic, dt)
 
-	// Add small noise to break symmetry
-	n.MembranePotential += (rand.Float64() - 0.5) * 0.05
+	n.MembranePotential += noise
 
 	// Calculate effective threshold with adaptive component
 	effectiveThreshold := n.Threshold + n.AdaptiveThreshold
 
 	// Check for spike
-	if n.MembranePotential >= effectiveThreshold {
+	if n.fires(effectiveThreshold, dt, escape) {
 		n.MembranePotential = 0
-		n.RefractoryTimer = n.RefractoryPeriod
+		n.RefractoryTimer = steps(float64(n.RefractoryPeriod), dt)
 		n.LastSpikeTime = currentTime
 		n.Fired = true
 
-		// STDP with diminishing returns
+		// STDP with diminishing returns, once per spike whatever the timestep
 		for i := range n.Connections {
-			if inputs[i] > 0 {
+			if inputs[i] > 0 && n.Connections[i].Pre != Untyped {
+				rule := rules.rule(n.Connections[i].Pre, n.Type)
+				n.typedUpdate(&n.Connections[i], learningRate*0.5*rule.LTP)
+			} else if inputs[i] > 0 {
 				// Scale learning by current weight (prevent saturation)
 				scale := 1.0 - math.Abs(n.Connections[i].Weight)/n.MaxWeight
 				n.Connections[i].Weight = clamp(
@@ -104,6 +210,11 @@ func (n *SpikingNeuron) Forward(inputs []float64, currentTime int, learningRate
 			n.Connections[i].LastPostSpike = currentTime
 		}
 
+		if n.Homeostasis != nil {
+			n.homeostasis(true, dt)
+			return 1
+		}
+
 		// Adjust adaptive threshold (makes firing harder after each spike)
 		n.AdaptiveThreshold += 0.1
 
@@ -112,26 +223,43 @@ func (n *SpikingNeuron) Forward(inputs []float64, currentTime int, learningRate
 		return 1
 	}
 
-	// Gradually relax adaptive threshold
-	n.AdaptiveThreshold *= 0.9
-
-	// LTD - depress all active connections when we don't fire
+	// LTD - depress all active connections when we don't fire, per millisecond
 	for i, input := range inputs {
-		if input > 0 {
+		if input > 0 && n.Connections[i].Pre != Untyped {
+			rule := rules.rule(n.Connections[i].Pre, n.Type)
+			n.typedUpdate(&n.Connections[i], -learningRate*0.1*rule.LTD*dt)
+		} else if input > 0 {
 			// Gentler depression that weakens over time
 			n.Connections[i].Weight = clamp(
-				n.Connections[i].Weight-learningRate*0.1*(1-math.Abs(n.Connections[i].Weight)/n.MaxWeight),
+				n.Connections[i].Weight-learningRate*0.1*dt*(1-math.Abs(n.Connections[i].Weight)/n.MaxWeight),
 				n.MinWeight,
 				n.MaxWeight,
 			)
 		}
 	}
 
+	if n.Homeostasis != nil {
+		n.homeostasis(false, dt)
+		return 0
+	}
+
+	// Gradually relax adaptive threshold
+	n.AdaptiveThreshold *= kept(0.9, dt)
+
 	// Adjust bias to make firing slightly easier next time
-	n.Bias = clamp(n.Bias+learningRate*0.05, n.MinBias, n.MaxBias)
+	n.Bias = clamp(n.Bias+learningRate*0.05*dt, n.MinBias, n.MaxBias)
 	return 0
 }
 
+// split adds a synaptic drive to the excitation or, when negative, to the
+// inhibition
+func split(drive, excitation, inhibition float64) (float64, float64) {
+	if drive >= 0 {
+		return excitation + drive, inhibition
+	}
+	return excitation, inhibition - drive
+}
+
 func clamp(x, min, max float64) float64 {
 	if x < min {
 		return min
@@ -0,0 +1,136 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// NoiseKind selects the model of a neuron's membrane noise
+type NoiseKind string
+
+const (
+	// NoNoise leaves the membrane noiseless
+	NoNoise NoiseKind = "none"
+	// UniformNoise kicks the potential by a uniform draw of width Amplitude
+	// every step, scaled by the square root of the timestep
+	UniformNoise NoiseKind = "uniform"
+	// GaussianNoise is white noise: a Gaussian kick with standard deviation
+	// Sigma·√dt every step, so the spread after a millisecond does not depend
+	// on the timestep
+	GaussianNoise NoiseKind = "gaussian"
+	// OUNoise is an Ornstein–Uhlenbeck background current with mean Mean,
+	// stationary standard deviation Sigma and correlation time Tau
+	OUNoise NoiseKind = "ou"
+	// PoissonNoise is background synaptic bombardment: input events arrive
+	// at Rate per millisecond and each moves the potential by Weight
+	PoissonNoise NoiseKind = "poisson"
+)
+
+// Noise is the membrane noise of a neuron. Neurons without one get the
+// original symmetry-breaking noise, DefaultNoise. Draws come from the
+// network's random source, so seeded networks stay reproducible; the OU
+// current is saved with the neuron.
+type Noise struct {
+	Kind      NoiseKind `json:"kind"`
+	Amplitude float64   `json:"amplitude,omitempty"` // uniform: width of the kick per √ms
+	Sigma     float64   `json:"sigma,omitempty"`     // gaussian: kick per √ms; ou: spread of the current
+	Mean      float64   `json:"mean,omitempty"`      // ou: mean current per ms
+	Tau       float64   `json:"tau,omitempty"`       // ou: correlation time in ms
+	Rate      float64   `json:"rate,omitempty"`      // poisson: events per ms
+	Weight    float64   `json:"weight,omitempty"`    // poisson: potential change per event
+
+	Current float64 `json:"current,omitempty"` // ou: the background current
+}
+
+// DefaultNoise is the noise of neurons that have none set: a small uniform
+// kick that breaks the symmetry between identical neurons
+func DefaultNoise() Noise {
+	return Noise{Kind: UniformNoise, Amplitude: 0.05}
+}
+
+// Validate checks the kind and its parameters
+func (n *Noise) Validate() error {
+	switch n.Kind {
+	case NoNoise:
+	case UniformNoise:
+		if n.Amplitude < 0 {
+			return fmt.Errorf("noise: uniform amplitude must not be negative, got %v", n.Amplitude)
+		}
+	case GaussianNoise:
+		if n.Sigma < 0 {
+			return fmt.Errorf("noise: gaussian sigma must not be negative, got %v", n.Sigma)
+		}
+	case OUNoise:
+		if n.Sigma < 0 || n.Tau <= 0 {
+			return fmt.Errorf("noise: ou needs a positive tau and non-negative sigma, got tau %v and sigma %v", n.Tau, n.Sigma)
+		}
+	case PoissonNoise:
+		if n.Rate < 0 {
+			return fmt.Errorf("noise: poisson rate must not be negative, got %v", n.Rate)
+		}
+	case "":
+		return fmt.Errorf("noise: missing kind")
+	default:
+		return fmt.Errorf("noise: unknown kind %q", n.Kind)
+	}
+	return nil
+}
+
+// SetNoise gives every neuron of the layer its own copy of n, with an OU
+// current starting at its mean
+func (l *Layer) SetNoise(n Noise) {
+	for i := range l.Neurons {
+		c := n
+		c.Current = n.Mean
+		l.Neurons[i].Noise = &c
+	}
+}
+
+// kick advances the noise by a step of dt milliseconds and returns how much
+// it moves the membrane potential, drawing from rng or the global source
+// when nil
+func (n *Noise) kick(rng *rand.Rand, dt float64) float64 {
+	switch n.Kind {
+	case UniformNoise:
+		return (uniform(rng) - 0.5) * n.Amplitude * math.Sqrt(dt)
+	case GaussianNoise:
+		return normal(rng) * n.Sigma * math.Sqrt(dt)
+	case OUNoise:
+		// Exact update over the step, so the statistics of the current do
+		// not depend on the timestep
+		d := math.Exp(-dt / n.Tau)
+		n.Current = n.Mean + (n.Current-n.Mean)*d + n.Sigma*math.Sqrt(1-d*d)*normal(rng)
+		return n.Current * dt
+	case PoissonNoise:
+		return float64(poisson(rng, n.Rate*dt)) * n.Weight
+	}
+	return 0
+}
+
+// noise returns the membrane noise of the neuron for a step of dt
+// milliseconds
+func (n *SpikingNeuron) noise(rng *rand.Rand, dt float64) float64 {
+	if n.Noise == nil {
+		d := DefaultNoise()
+		return d.kick(rng, dt)
+	}
+	return n.Noise.kick(rng, dt)
+}
+
+// poisson draws a Poisson count with mean lambda, using a normal
+// approximation for large means
+func poisson(rng *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > 30 {
+		return max(0, int(math.Round(lambda+math.Sqrt(lambda)*normal(rng))))
+	}
+	limit, k, p := math.Exp(-lambda), 0, uniform(rng)
+	for p > limit {
+		k++
+		p *= uniform(rng)
+	}
+	return k
+}
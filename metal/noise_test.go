@@ -0,0 +1,166 @@
+package neuron
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"tinybrain/initializer"
+)
+
+// spread returns the mean and standard deviation of the total kick of the
+// noise over each millisecond at timestep dt
+func spread(n Noise, dt float64) (float64, float64) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	const samples = 20000
+	per := int(math.Round(1 / dt))
+	sum, sq := 0.0, 0.0
+	for s := 0; s < samples; s++ {
+		total := 0.0
+		for k := 0; k < per; k++ {
+			total += n.kick(rng, dt)
+		}
+		sum += total
+		sq += total * total
+	}
+	mean := sum / samples
+	return mean, math.Sqrt(sq/samples - mean*mean)
+}
+
+func TestNoiseSpreadIndependentOfTimeStep(t *testing.T) {
+	for _, n := range []Noise{
+		{Kind: GaussianNoise, Sigma: 0.2},
+		{Kind: UniformNoise, Amplitude: 0.2},
+		{Kind: PoissonNoise, Rate: 0.5, Weight: 0.1},
+	} {
+		coarseMean, coarse := spread(n, 1)
+		fineMean, fine := spread(n, 0.1)
+		if math.Abs(coarse-fine)/coarse > 0.05 || math.Abs(coarseMean-fineMean) > 0.01 {
+			t.Errorf("%s: %.4f ± %.4f per ms at dt 1, %.4f ± %.4f at dt 0.1", n.Kind, coarseMean, coarse, fineMean, fine)
+		}
+	}
+	if _, s := spread(Noise{Kind: GaussianNoise, Sigma: 0.2}, 1); math.Abs(s-0.2) > 0.01 {
+		t.Errorf("gaussian: spread %.4f per ms, want 0.2", s)
+	}
+	if m, _ := spread(Noise{Kind: PoissonNoise, Rate: 0.5, Weight: 0.1}, 0.1); math.Abs(m-0.05) > 0.005 {
+		t.Errorf("poisson: mean %.4f per ms, want 0.05", m)
+	}
+}
+
+func TestNoiseOrnsteinUhlenbeckStatistics(t *testing.T) {
+	for _, dt := range []float64{1, 0.1} {
+		n := Noise{Kind: OUNoise, Mean: 0.1, Sigma: 0.3, Tau: 5}
+		n.Current = n.Mean
+		rng := rand.New(rand.NewPCG(3, 4))
+		sum, sq, lagged := 0.0, 0.0, 0.0
+		lag := int(math.Round(n.Tau / dt))
+		var history []float64
+		for i := 0; i < int(math.Round(20000/dt)); i++ {
+			n.kick(rng, dt)
+			history = append(history, n.Current)
+			sum += n.Current
+			sq += n.Current * n.Current
+		}
+		count := float64(len(history))
+		mean := sum / count
+		std := math.Sqrt(sq/count - mean*mean)
+		for i := lag; i < len(history); i++ {
+			lagged += (history[i] - mean) * (history[i-lag] - mean)
+		}
+		correlation := lagged / float64(len(history)-lag) / (std * std)
+		if math.Abs(mean-0.1) > 0.02 || math.Abs(std-0.3) > 0.03 {
+			t.Errorf("dt %v: current %.3f ± %.3f, want 0.1 ± 0.3", dt, mean, std)
+		}
+		// Correlation after one time constant is 1/e
+		if math.Abs(correlation-math.Exp(-1)) > 0.05 {
+			t.Errorf("dt %v: correlation %.3f after tau, want %.3f", dt, correlation, math.Exp(-1))
+		}
+	}
+}
+
+func TestNoiseSeededAndSaved(t *testing.T) {
+	build := func() *Network {
+		net, err := NewBuilder(2).Seed(9).
+			Layer("out", 4).With(func(l *Layer) error {
+			l.SetNoise(Noise{Kind: OUNoise, Mean: 0.05, Sigma: 0.2, Tau: 10})
+			return nil
+		}).Done().
+			Project(Input, "out", AllToAll{}, initializer.Constant(0.3)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return net
+	}
+	run := func(net *Network) []float64 {
+		if _, err := net.Run(100, func(int) []float64 { return []float64{1, 0} }, 0); err != nil {
+			t.Fatal(err)
+		}
+		var currents []float64
+		for _, n := range net.Layers[0].Neurons {
+			currents = append(currents, n.Noise.Current)
+		}
+		return currents
+	}
+	a, b := run(build()), run(build())
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("neuron %d: currents %v and %v from the same seed", i, a[i], b[i])
+		}
+	}
+
+	net := build()
+	run(net)
+	data, err := json.Marshal(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Network
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	noise := saved.Layers[0].Neurons[0].Noise
+	if noise == nil || noise.Kind != OUNoise || noise.Current != net.Layers[0].Neurons[0].Noise.Current {
+		t.Errorf("saved noise %+v, want %+v", noise, net.Layers[0].Neurons[0].Noise)
+	}
+}
+
+func TestNoiseValidate(t *testing.T) {
+	for _, n := range []Noise{
+		{},
+		{Kind: "pink"},
+		{Kind: UniformNoise, Amplitude: -1},
+		{Kind: GaussianNoise, Sigma: -1},
+		{Kind: OUNoise, Sigma: 0.1},
+		{Kind: PoissonNoise, Rate: -1},
+	} {
+		if err := n.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", n)
+		}
+	}
+	for _, n := range []Noise{{Kind: NoNoise}, DefaultNoise(), {Kind: OUNoise, Tau: 5, Sigma: 0.1}} {
+		if err := n.Validate(); err != nil {
+			t.Errorf("%+v: %v", n, err)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidNoise(t *testing.T) {
+	for _, noise := range []Noise{
+		{Kind: "pink", Sigma: 0.1},
+		{Kind: OUNoise, Sigma: 0.1, Tau: 0},
+		{Kind: OUNoise, Sigma: 0.1, Tau: -5},
+	} {
+		net, err := NewNetwork([]*Layer{wired(2, 3)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.Layers[0].Neurons[2].Noise = &noise
+		loaded := &Network{}
+		if err := loaded.Load(saved(t, net)); err == nil || !strings.Contains(err.Error(), "neuron 2: noise") {
+			t.Errorf("saved noise %+v: got %v", noise, err)
+		}
+	}
+}
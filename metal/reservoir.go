@@ -0,0 +1,303 @@
+package neuron
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+)
+
+// ReservoirConfig describes a liquid state machine: a single layer of
+// randomly and recurrently connected excitatory and inhibitory neurons,
+// driven by sparse input connections and read out linearly
+type ReservoirConfig struct {
+	Inputs           int
+	Size             int     // neurons in the reservoir
+	Density          float64 // probability of each recurrent connection
+	InputDensity     float64 // probability of each input connection
+	InputWeight      float64 // mean input weight
+	Excitatory       float64 // fraction of excitatory neurons, e.g. 0.8
+	Balance          float64 // total inhibitory over total excitatory recurrent weight; 1 balances them
+	SpectralRadius   float64 // recurrent weights are scaled to this spectral radius; 0 keeps them unscaled
+	Threshold        float64
+	Decay            float64
+	RefractoryPeriod int
+}
+
+// NewReservoir builds a reservoir network obeying Dale's law. The first
+// neurons are excitatory and the rest inhibitory; inputs are excitatory.
+// Recurrent weight magnitudes are uniform in [0, 1) before inhibitory ones
+// are scaled by Balance and all by the spectral radius; input weights vary
+// by ±50% around InputWeight. Draws come from rng, or the global source when
+// nil. Weight bounds are set to the largest weight, so that learning, if any,
+// starts unclipped.
+func NewReservoir(cfg ReservoirConfig, rng *rand.Rand) (*Network, error) {
+	if cfg.Inputs < 1 || cfg.Size < 1 {
+		return nil, errors.New("reservoir: need inputs and neurons")
+	}
+	if cfg.Density < 0 || cfg.Density > 1 || cfg.InputDensity < 0 || cfg.InputDensity > 1 {
+		return nil, errors.New("reservoir: densities must be in [0, 1]")
+	}
+	if cfg.Excitatory <= 0 || cfg.Excitatory > 1 {
+		return nil, fmt.Errorf("reservoir: excitatory fraction must be in (0, 1], got %v", cfg.Excitatory)
+	}
+	if cfg.SpectralRadius < 0 {
+		return nil, fmt.Errorf("reservoir: spectral radius must not be negative, got %v", cfg.SpectralRadius)
+	}
+
+	excitatory := int(math.Round(cfg.Excitatory * float64(cfg.Size)))
+	neurons := make([]SpikingNeuron, cfg.Size)
+	for j := range neurons {
+		n := NewSpikingNeuron(0, cfg.Threshold, cfg.Decay, 0, cfg.RefractoryPeriod)
+		n.Type = Excitatory
+		if j >= excitatory {
+			n.Type = Inhibitory
+		}
+		neurons[j] = *n
+	}
+	layer := NewLayer(neurons)
+
+	inhibition := 0.0
+	if excitatory < cfg.Size {
+		inhibition = cfg.Balance * float64(excitatory) / float64(cfg.Size-excitatory)
+	}
+	layer.Connect(func(post, pre int) float64 {
+		if pre == post || uniform(rng) >= cfg.Density {
+			return 0
+		}
+		if pre >= excitatory {
+			return -inhibition * uniform(rng)
+		}
+		return uniform(rng)
+	})
+	input := func(pre, post int) float64 { return cfg.InputWeight * (0.5 + uniform(rng)) }
+	if err := layer.Wire(cfg.Inputs, FixedProbability{P: cfg.InputDensity}, input, rng); err != nil {
+		return nil, fmt.Errorf("reservoir: %w", err)
+	}
+	if cfg.SpectralRadius > 0 {
+		layer.ScaleRecurrent(cfg.SpectralRadius, rng)
+	}
+
+	bound := 0.0
+	for j := range layer.Neurons {
+		n := &layer.Neurons[j]
+		for i := range n.Connections {
+			n.Connections[i].Pre = Excitatory
+			bound = max(bound, math.Abs(n.Connections[i].Weight))
+		}
+		for i := range n.Recurrent {
+			n.Recurrent[i].Pre = layer.Neurons[i].Type
+			bound = max(bound, math.Abs(n.Recurrent[i].Weight))
+		}
+	}
+	for j := range layer.Neurons {
+		layer.Neurons[j].MinWeight, layer.Neurons[j].MaxWeight = -bound, bound
+	}
+	return NewNetwork([]*Layer{layer})
+}
+
+// SpectralRadius estimates the largest eigenvalue magnitude of the layer's
+// recurrent weight matrix from the growth rate of repeated products with a
+// random vector drawn from rng (or the global source when nil). The estimate
+// averages over enough steps to handle complex eigenvalue pairs.
+func (l *Layer) SpectralRadius(rng *rand.Rand) float64 {
+	const steps = 500
+	x := make([]float64, len(l.Neurons))
+	for i := range x {
+		x[i] = uniform(rng) + 0.1
+	}
+	growth := 0.0
+	for k := 0; k < steps; k++ {
+		y := make([]float64, len(x))
+		norm := 0.0
+		for j := range l.Neurons {
+			for i, c := range l.Neurons[j].Recurrent {
+				y[j] += c.Weight * x[i]
+			}
+			norm += y[j] * y[j]
+		}
+		norm = math.Sqrt(norm)
+		if norm == 0 {
+			return 0
+		}
+		if k >= steps/2 {
+			growth += math.Log(norm)
+		}
+		for i := range y {
+			x[i] = y[i] / norm
+		}
+	}
+	return math.Exp(growth / float64(steps-steps/2))
+}
+
+// ScaleRecurrent scales the recurrent weights to the given spectral radius,
+// estimated with SpectralRadius. Weight bounds are left alone.
+func (l *Layer) ScaleRecurrent(radius float64, rng *rand.Rand) {
+	current := l.SpectralRadius(rng)
+	if current == 0 {
+		return
+	}
+	for j := range l.Neurons {
+		for i := range l.Neurons[j].Recurrent {
+			l.Neurons[j].Recurrent[i].Weight *= radius / current
+		}
+	}
+}
+
+// StateCollector filters the spikes of one layer into a feature vector: each
+// neuron's trace decays by Decay every step and grows by 1 when it fires
+type StateCollector struct {
+	Decay float64
+	trace []float64
+}
+
+// NewStateCollector registers hooks on net that filter the spikes of the
+// given layer
+func NewStateCollector(net *Network, layer int, decay float64) *StateCollector {
+	s := &StateCollector{Decay: decay, trace: make([]float64, len(net.Layers[layer].Neurons))}
+	net.OnStepStart(func(*Network, int) {
+		for i := range s.trace {
+			s.trace[i] *= s.Decay
+		}
+	})
+	net.OnSpike(func(_ *Network, e Event) {
+		if e.Layer == layer {
+			s.trace[e.Neuron]++
+		}
+	})
+	return s
+}
+
+// State returns a copy of the current traces
+func (s *StateCollector) State() []float64 {
+	return append([]float64(nil), s.trace...)
+}
+
+// Reset clears the traces
+func (s *StateCollector) Reset() {
+	clear(s.trace)
+}
+
+// LinearReadout maps a state to outputs: Weights[k]·state + Bias[k]
+type LinearReadout struct {
+	Weights [][]float64 `json:"weights"`
+	Bias    []float64   `json:"bias"`
+}
+
+// TrainReadout fits a readout to targets by ridge regression: it minimises
+// the squared error plus ridge times the squared weights, leaving the bias
+// unpenalised
+func TrainReadout(states, targets [][]float64, ridge float64) (*LinearReadout, error) {
+	if len(states) == 0 || len(states) != len(targets) {
+		return nil, fmt.Errorf("readout: %d states for %d targets", len(states), len(targets))
+	}
+	if ridge < 0 {
+		return nil, fmt.Errorf("readout: ridge must not be negative, got %v", ridge)
+	}
+	features, outputs := len(states[0]), len(targets[0])
+
+	// Normal equations over the states extended by a constant 1 for the bias
+	size := features + 1
+	a := make([][]float64, size)
+	b := make([][]float64, size)
+	for i := range a {
+		a[i] = make([]float64, size)
+		b[i] = make([]float64, outputs)
+	}
+	x := make([]float64, size)
+	for s, state := range states {
+		if len(state) != features || len(targets[s]) != outputs {
+			return nil, fmt.Errorf("readout: sample %d has %d features and %d targets, want %d and %d", s, len(state), len(targets[s]), features, outputs)
+		}
+		copy(x, state)
+		x[features] = 1
+		for i := range x {
+			for j := range x {
+				a[i][j] += x[i] * x[j]
+			}
+			for k, y := range targets[s] {
+				b[i][k] += x[i] * y
+			}
+		}
+	}
+	for i := 0; i < features; i++ {
+		a[i][i] += ridge
+	}
+	if err := solve(a, b); err != nil {
+		return nil, err
+	}
+
+	r := &LinearReadout{Weights: make([][]float64, outputs), Bias: make([]float64, outputs)}
+	for k := range r.Weights {
+		r.Weights[k] = make([]float64, features)
+		for i := range r.Weights[k] {
+			r.Weights[k][i] = b[i][k]
+		}
+		r.Bias[k] = b[features][k]
+	}
+	return r, nil
+}
+
+// solve overwrites b with the solution of a·x = b by Gaussian elimination
+// with partial pivoting
+func solve(a, b [][]float64) error {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return errors.New("readout: singular system, add a ridge penalty")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			if f == 0 {
+				continue
+			}
+			for k := col; k < n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			for k := range b[row] {
+				b[row][k] -= f * b[col][k]
+			}
+		}
+	}
+	for row := 0; row < n; row++ {
+		for k := range b[row] {
+			b[row][k] /= a[row][row]
+		}
+	}
+	return nil
+}
+
+// Output returns the readout of a state
+func (r *LinearReadout) Output(state []float64) []float64 {
+	out := make([]float64, len(r.Weights))
+	for k, w := range r.Weights {
+		out[k] = r.Bias[k]
+		for i, x := range state {
+			out[k] += w[i] * x
+		}
+	}
+	return out
+}
+
+// Classify returns the index of the largest output
+func (r *LinearReadout) Classify(state []float64) int {
+	out := r.Output(state)
+	best := 0
+	for k, v := range out {
+		if v > out[best] {
+			best = k
+		}
+	}
+	return best
+}
@@ -0,0 +1,151 @@
+package neuron
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// delayedRecall presents one of several fixed spike patterns, waits through
+// a silent delay and returns the reservoir state at its end with the label
+// of the pattern, for the given number of trials
+func delayedRecall(t *testing.T, net *Network, collector *StateCollector, patterns [][][]float64, delay, trials int, rng *rand.Rand) ([][]float64, []int) {
+	t.Helper()
+	silence := make([]float64, len(patterns[0][0]))
+	var states [][]float64
+	var labels []int
+	for trial := 0; trial < trials; trial++ {
+		label := rng.IntN(len(patterns))
+		for _, frame := range patterns[label] {
+			// Jitter the pattern by dropping a few of its spikes
+			input := make([]float64, len(frame))
+			for i, v := range frame {
+				if v > 0 && rng.Float64() > 0.1 {
+					input[i] = 1
+				}
+			}
+			if _, err := net.Forward(input, net.Time, 0); err != nil {
+				t.Fatal(err)
+			}
+			net.Time++
+		}
+		for step := 0; step < delay; step++ {
+			if _, err := net.Forward(silence, net.Time, 0); err != nil {
+				t.Fatal(err)
+			}
+			net.Time++
+		}
+		states = append(states, collector.State())
+		labels = append(labels, label)
+	}
+	return states, labels
+}
+
+func oneHot(labels []int, classes int) [][]float64 {
+	targets := make([][]float64, len(labels))
+	for i, label := range labels {
+		targets[i] = make([]float64, classes)
+		targets[i][label] = 1
+	}
+	return targets
+}
+
+func TestReservoirDelayedRecall(t *testing.T) {
+	const inputs, classes, length, delay = 20, 4, 5, 6
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	patterns := make([][][]float64, classes)
+	for c := range patterns {
+		patterns[c] = make([][]float64, length)
+		for step := range patterns[c] {
+			patterns[c][step] = make([]float64, inputs)
+			for i := range patterns[c][step] {
+				if rng.Float64() < 0.2 {
+					patterns[c][step][i] = 1
+				}
+			}
+		}
+	}
+
+	net, err := NewReservoir(ReservoirConfig{
+		Inputs:           inputs,
+		Size:             200,
+		Density:          0.1,
+		InputDensity:     0.2,
+		InputWeight:      0.6,
+		Excitatory:       0.8,
+		Balance:          1,
+		SpectralRadius:   1,
+		Threshold:        1,
+		Decay:            0.9,
+		RefractoryPeriod: 2,
+	}, rand.New(rand.NewPCG(3, 4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetSeed(5)
+	collector := NewStateCollector(net, 0, 0.5)
+
+	trials := rand.New(rand.NewPCG(6, 7))
+	train, labels := delayedRecall(t, net, collector, patterns, delay, 300, trials)
+	readout, err := TrainReadout(train, oneHot(labels, classes), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test, labels := delayedRecall(t, net, collector, patterns, delay, 200, trials)
+	correct := 0
+	for i, state := range test {
+		if readout.Classify(state) == labels[i] {
+			correct++
+		}
+	}
+	accuracy := float64(correct) / float64(len(test))
+	t.Logf("recalled %.2f of the patterns after %d silent steps", accuracy, delay)
+	if accuracy < 0.9 {
+		t.Errorf("reservoir recalls %.2f of the patterns, want at least 0.9", accuracy)
+	}
+}
+
+func TestReservoirSpectralRadius(t *testing.T) {
+	net, err := NewReservoir(ReservoirConfig{
+		Inputs: 5, Size: 100, Density: 0.2, InputDensity: 0.5, InputWeight: 0.5,
+		Excitatory: 0.8, Balance: 1, SpectralRadius: 1.5, Threshold: 1, Decay: 0.9,
+	}, rand.New(rand.NewPCG(1, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := net.Layers[0]
+	if got := layer.SpectralRadius(rand.New(rand.NewPCG(2, 2))); math.Abs(got-1.5) > 0.05 {
+		t.Errorf("spectral radius %v, want 1.5", got)
+	}
+	for j, n := range layer.Neurons {
+		for i, c := range n.Recurrent {
+			if layer.Neurons[i].Type == Excitatory && c.Weight < 0 || layer.Neurons[i].Type == Inhibitory && c.Weight > 0 {
+				t.Fatalf("connection %d->%d has weight %v against Dale's law", i, j, c.Weight)
+			}
+		}
+	}
+}
+
+func TestTrainReadoutFitsLinearMap(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 1))
+	var states, targets [][]float64
+	for range 50 {
+		x := []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+		states = append(states, x)
+		targets = append(targets, []float64{2*x[0] - x[2] + 0.5})
+	}
+	readout, err := TrainReadout(states, targets, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{2, 0, -1}
+	for i, w := range readout.Weights[0] {
+		if math.Abs(w-want[i]) > 1e-9 {
+			t.Errorf("weight %d is %v, want %v", i, w, want[i])
+		}
+	}
+	if math.Abs(readout.Bias[0]-0.5) > 1e-9 {
+		t.Errorf("bias %v, want 0.5", readout.Bias[0])
+	}
+}
@@ -16,7 +16,11 @@ func (n *Network) Load(filename string) error {
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(file, n)
+	n.rng = nil
+	if err := json.Unmarshal(file, n); err != nil {
+		return err
+	}
+	return n.Validate()
 }
 
 // helper to save any object as JSON
@@ -0,0 +1,182 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// InputIndex returns the index of the input that Connections[i] reads
+func (n *SpikingNeuron) InputIndex(i int) int {
+	if n.Sparse {
+		return n.Connections[i].Source
+	}
+	return i
+}
+
+// InputSize is the number of inputs the neuron is known to read: all of its
+// connections when dense, up to its highest source when sparse
+func (n *SpikingNeuron) InputSize() int {
+	if !n.Sparse {
+		return len(n.Connections)
+	}
+	size := 0
+	for _, c := range n.Connections {
+		size = max(size, c.Source+1)
+	}
+	return size
+}
+
+// checkInputs reports whether the neuron can read an input of the given size
+func (n *SpikingNeuron) checkInputs(size int) error {
+	if !n.Sparse {
+		if size != len(n.Connections) {
+			return fmt.Errorf("%d connections for %d inputs", len(n.Connections), size)
+		}
+		return nil
+	}
+	read := make([]bool, size)
+	for i, c := range n.Connections {
+		if c.Source < 0 || c.Source >= size {
+			return fmt.Errorf("connection %d reads input %d of %d", i, c.Source, size)
+		}
+		if read[c.Source] {
+			return fmt.Errorf("connection %d reads input %d twice", i, c.Source)
+		}
+		read[c.Source] = true
+	}
+	return nil
+}
+
+// presynaptic returns the input of every connection, in connection order. For
+// sparse neurons the result is a buffer that the next call overwrites.
+func (n *SpikingNeuron) presynaptic(inputs []float64) []float64 {
+	if !n.Sparse {
+		return inputs
+	}
+	if len(n.gathered) != len(n.Connections) {
+		n.gathered = make([]float64, len(n.Connections))
+	}
+	for i, c := range n.Connections {
+		n.gathered[i] = inputs[c.Source]
+	}
+	return n.gathered
+}
+
+// makeSparse switches a dense neuron to explicit sources
+func (n *SpikingNeuron) makeSparse() {
+	if n.Sparse {
+		return
+	}
+	for i := range n.Connections {
+		n.Connections[i].Source = i
+	}
+	n.Sparse = true
+}
+
+// InputSize is the width of the layer's input: Inputs when it is recorded,
+// otherwise the widest input any neuron reads
+func (l *Layer) InputSize() int {
+	if l.Inputs > 0 {
+		return l.Inputs
+	}
+	size := 0
+	for i := range l.Neurons {
+		size = max(size, l.Neurons[i].InputSize())
+	}
+	return size
+}
+
+// Structural is structural plasticity: synapses whose weight stays weak for
+// Window milliseconds are pruned, and each neuron grows a synapse from a
+// random unconnected input with probability GrowthRate per millisecond. Pruning turns a
+// neuron sparse. Recurrent connections are left alone; new synapses drive the
+// soma and copy the short-term plasticity parameters of the neuron's first
+// connection.
+type Structural struct {
+	PruneThreshold float64 `json:"pruneThreshold"`        // synapses with a smaller weight magnitude are weak
+	Window         int     `json:"window"`                // milliseconds a synapse must stay weak to be pruned
+	GrowthRate     float64 `json:"growthRate"`            // probability per ms that a neuron grows a synapse
+	InitialWeight  float64 `json:"initialWeight"`         // magnitude of a new synapse; its sign follows Dale's law
+	MaxInDegree    int     `json:"maxInDegree,omitempty"` // 0 lets a neuron connect to every input
+}
+
+// Validate checks the window and rates
+func (s *Structural) Validate() error {
+	if s.Window < 1 {
+		return fmt.Errorf("structural: window must be at least 1 ms, got %d", s.Window)
+	}
+	if s.PruneThreshold < 0 || s.InitialWeight < 0 {
+		return fmt.Errorf("structural: prune threshold and initial weight must not be negative")
+	}
+	if s.GrowthRate < 0 || s.GrowthRate > 1 {
+		return fmt.Errorf("structural: growth rate must be in [0, 1], got %v", s.GrowthRate)
+	}
+	return nil
+}
+
+// restructure prunes and grows the synapses of every neuron for a step of dt
+// milliseconds. It runs after the layer's forward pass, drawing from rng (or the global source
+// when nil) neuron by neuron so that seeded networks stay reproducible. pre
+// gives the type of each input for new synapses.
+func (l *Layer) restructure(inputs int, dt float64, rng *rand.Rand, pre func(input int) NeuronType) {
+	s := l.Structural
+	l.Inputs = inputs
+	window := max(steps(float64(s.Window), dt), 1)
+	growth := chance(s.GrowthRate, dt)
+	limit := inputs
+	if s.MaxInDegree > 0 {
+		limit = min(limit, s.MaxInDegree)
+	}
+
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+
+		pruned := false
+		for i := range n.Connections {
+			c := &n.Connections[i]
+			if math.Abs(c.Weight) < s.PruneThreshold {
+				c.WeakFor++
+				pruned = pruned || c.WeakFor >= window
+			} else {
+				c.WeakFor = 0
+			}
+		}
+		if pruned {
+			n.makeSparse()
+			n.Connections = slices.DeleteFunc(n.Connections, func(c Connection) bool {
+				return c.WeakFor >= window
+			})
+		}
+
+		// One draw per neuron and step, whether or not it grows
+		if uniform(rng) >= growth || len(n.Connections) >= limit {
+			continue
+		}
+		connected := make([]bool, inputs)
+		for i := range n.Connections {
+			connected[n.InputIndex(i)] = true
+		}
+		free := inputs - len(n.Connections)
+		k := int(uniform(rng) * float64(free))
+		for source, taken := range connected {
+			if taken {
+				continue
+			}
+			if k == 0 {
+				n.makeSparse()
+				c := Connection{Weight: s.InitialWeight, LastPreSpike: -100, Source: source, Pre: pre(source)}
+				if len(n.Connections) > 0 && n.Connections[0].STP != nil {
+					stp := n.Connections[0].STP
+					c.STP = NewShortTermPlasticity(stp.U, stp.TauRec, stp.TauFac)
+				}
+				signDale(&c)
+				n.clampWeight(&c)
+				n.Connections = append(n.Connections, c)
+				break
+			}
+			k--
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package neuron
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// structural returns a network of one layer of size neurons that reads
+// inputs values through the given weights and rewires itself with s
+func structural(t *testing.T, inputs, size int, weight float64, s Structural) *Network {
+	t.Helper()
+	layer := wired(inputs, size)
+	for j := range layer.Neurons {
+		for i := range layer.Neurons[j].Connections {
+			layer.Neurons[j].Connections[i].Weight = weight
+		}
+	}
+	layer.Structural = &s
+	net, err := NewNetwork([]*Layer{layer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetSeed(7)
+	return net
+}
+
+// run steps the network without input or learning for ms milliseconds
+func run(t *testing.T, net *Network, ms float64) {
+	t.Helper()
+	input := make([]float64, net.InputSize())
+	for step := range net.Steps(ms) {
+		if _, err := net.Forward(input, step, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// seedling leaves every neuron of the layer one synapse, from its first input
+func seedling(l *Layer) {
+	l.Inputs = l.InputSize()
+	for j := range l.Neurons {
+		n := &l.Neurons[j]
+		n.makeSparse()
+		n.Connections = n.Connections[:1]
+	}
+}
+
+func TestStructuralPrunesAfterWindow(t *testing.T) {
+	for _, dt := range []float64{1, 0.5} {
+		net := structural(t, 3, 1, 0.5, Structural{PruneThreshold: 0.1, Window: 4})
+		net.DT = dt
+		n := &net.Layers[0].Neurons[0]
+		n.Connections[1].Weight = 0.05
+
+		run(t, net, 3)
+		if n.Sparse || len(n.Connections) != 3 || n.Connections[1].WeakFor != net.Steps(3) {
+			t.Fatalf("dt %v: pruned before the window, %+v", dt, n.Connections)
+		}
+		run(t, net, 1)
+		if !n.Sparse || len(n.Connections) != 2 || n.Connections[0].Source != 0 || n.Connections[1].Source != 2 {
+			t.Errorf("dt %v: after the window, sparse %v with %+v, want inputs 0 and 2", dt, n.Sparse, n.Connections)
+		}
+		if net.Layers[0].Inputs != 3 {
+			t.Errorf("dt %v: layer records %d inputs, want 3", dt, net.Layers[0].Inputs)
+		}
+	}
+}
+
+func TestStructuralGrowth(t *testing.T) {
+	// From one synapse each, neurons grow at GrowthRate per millisecond
+	net := structural(t, 100, 50, 0.5, Structural{Window: 1, GrowthRate: 0.05, InitialWeight: 0.3})
+	seedling(net.Layers[0])
+	run(t, net, 40)
+	grown := 0
+	for _, n := range net.Layers[0].Neurons {
+		grown += len(n.Connections) - 1
+	}
+	if grown < 70 || grown > 130 {
+		t.Errorf("%d synapses grown, want about 50 neurons × 40 ms × 0.05 = 100", grown)
+	}
+	if err := net.Validate(); err != nil {
+		t.Errorf("grown synapses: %v", err)
+	}
+
+	// MaxInDegree caps the synapses of a neuron
+	net = structural(t, 8, 3, 0.5, Structural{Window: 1, GrowthRate: 1, InitialWeight: 0.3, MaxInDegree: 5})
+	seedling(net.Layers[0])
+	run(t, net, 20)
+	for j, n := range net.Layers[0].Neurons {
+		if len(n.Connections) != 5 {
+			t.Errorf("neuron %d: %d synapses, want the cap of 5", j, len(n.Connections))
+		}
+		for i, c := range n.Connections[1:] {
+			if c.Weight != 0.3 {
+				t.Errorf("neuron %d grown synapse %d: weight %v, want 0.3", j, i, c.Weight)
+			}
+		}
+	}
+	if err := net.Validate(); err != nil {
+		t.Errorf("capped synapses: %v", err)
+	}
+}
+
+func TestStructuralGrowthFollowsDale(t *testing.T) {
+	first := wired(2, 6)
+	for j := range first.Neurons {
+		first.Neurons[j].Type = Excitatory
+		if j%2 == 1 {
+			first.Neurons[j].Type = Inhibitory
+		}
+	}
+	second := wired(6, 4)
+	for j := range second.Neurons {
+		second.Neurons[j].makeSparse()
+		second.Neurons[j].Connections = nil
+	}
+	second.Inputs = 6
+	second.Structural = &Structural{Window: 1, GrowthRate: 1, InitialWeight: 0.4}
+	net, err := NewNetwork([]*Layer{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.SetSeed(3)
+	run(t, net, 6)
+
+	for j, n := range second.Neurons {
+		if len(n.Connections) != 6 {
+			t.Fatalf("neuron %d: %d synapses, want one from every input", j, len(n.Connections))
+		}
+		for i, c := range n.Connections {
+			want := first.Neurons[c.Source].Type
+			if c.Pre != want || (want == Inhibitory) != (c.Weight < 0) || c.Weight != 0.4 && c.Weight != -0.4 {
+				t.Errorf("neuron %d synapse %d from input %d: %q with weight %v, want %q", j, i, c.Source, c.Pre, c.Weight, want)
+			}
+		}
+	}
+}
+
+func TestStructuralSaveAndLoad(t *testing.T) {
+	net := structural(t, 6, 3, 0.5, Structural{PruneThreshold: 0.1, Window: 5, GrowthRate: 0.2, InitialWeight: 0.05})
+	for j := range net.Layers[0].Neurons {
+		net.Layers[0].Neurons[j].Connections[j].Weight = 0.05
+	}
+	run(t, net, 12)
+	weak := false
+	for _, n := range net.Layers[0].Neurons {
+		for _, c := range n.Connections {
+			weak = weak || c.WeakFor > 0
+		}
+	}
+	if !net.Layers[0].Neurons[0].Sparse || !weak {
+		t.Fatal("expected pruned neurons and synapses part way through their window")
+	}
+
+	path := filepath.Join(t.TempDir(), "net.json")
+	if err := net.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := &Network{}
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if state(t, loaded.Layers[0]) != state(t, net.Layers[0]) {
+		t.Error("the reloaded layer differs")
+	}
+	for j, n := range loaded.Layers[0].Neurons {
+		if !reflect.DeepEqual(n.Connections, net.Layers[0].Neurons[j].Connections) || n.Sparse != net.Layers[0].Neurons[j].Sparse {
+			t.Errorf("neuron %d: reloaded %+v, want %+v", j, n.Connections, net.Layers[0].Neurons[j].Connections)
+		}
+	}
+	if loaded.Layers[0].Inputs != 6 || loaded.InputSize() != 6 {
+		t.Errorf("reloaded layer reads %d inputs, want 6", loaded.Layers[0].Inputs)
+	}
+}
+
+func TestStructuralRejects(t *testing.T) {
+	conv, err := NewConvLayer(Convolution{Input: Shape{Channels: 1, Height: 3, Width: 3}, Channels: 1, Kernel: 2, Stride: 1},
+		SpikingNeuron{Threshold: 1, Decay: 0.9, MinWeight: -1, MaxWeight: 1}, func(int, int) float64 { return 0.1 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv.Structural = &Structural{Window: 1}
+	if _, err := NewNetwork([]*Layer{conv}); err == nil || !strings.Contains(err.Error(), "convolutional") {
+		t.Errorf("structural conv layer: got %v", err)
+	}
+
+	layer := wired(4, 1)
+	layer.Structural = &Structural{Window: 0}
+	if _, err := NewNetwork([]*Layer{layer}); err == nil || !strings.Contains(err.Error(), "window") {
+		t.Errorf("structural window of 0: got %v", err)
+	}
+
+	layer = wired(4, 1)
+	n := &layer.Neurons[0]
+	n.makeSparse()
+	n.Connections[2].Source = 1
+	layer.Inputs = 4
+	if _, err := NewNetwork([]*Layer{layer}); err == nil || !strings.Contains(err.Error(), "reads input 1 twice") {
+		t.Errorf("duplicate source: got %v", err)
+	}
+}
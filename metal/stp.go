@@ -0,0 +1,92 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+)
+
+// ShortTermPlasticity is Tsodyks–Markram facilitation and depression of a
+// connection. Each presynaptic spike releases a fraction Utilization of the
+// available Resources; resources recover towards 1 with TauRec and the
+// utilization relaxes back to U with TauFac, jumping up by U·(1-u) after
+// every spike. Time constants are in milliseconds; a zero TauFac gives a purely
+// depressing synapse with utilization fixed at U.
+//
+// The effective weight is Weight·u·x/U, so a synapse at rest transmits its
+// full weight. Inputs are treated as spike probabilities: an input of 0.5
+// releases and depletes half as much as a full spike.
+type ShortTermPlasticity struct {
+	U           float64 `json:"u"`           // utilization at rest
+	TauRec      float64 `json:"tauRec"`      // recovery time constant of the resources
+	TauFac      float64 `json:"tauFac"`      // facilitation time constant; 0 disables facilitation
+	Utilization float64 `json:"utilization"` // current u
+	Resources   float64 `json:"resources"`   // current x
+}
+
+// NewShortTermPlasticity returns a synapse at rest
+func NewShortTermPlasticity(u, tauRec, tauFac float64) *ShortTermPlasticity {
+	return &ShortTermPlasticity{U: u, TauRec: tauRec, TauFac: tauFac, Utilization: u, Resources: 1}
+}
+
+// Depressing returns the parameters of a depressing cortical synapse
+func Depressing() *ShortTermPlasticity {
+	return NewShortTermPlasticity(0.5, 80, 0)
+}
+
+// Facilitating returns the parameters of a facilitating cortical synapse
+// (Gupta et al. 2000, type F1)
+func Facilitating() *ShortTermPlasticity {
+	return NewShortTermPlasticity(0.16, 45, 376)
+}
+
+// Validate checks that the parameters describe a usable synapse
+func (s *ShortTermPlasticity) Validate() error {
+	if s.U <= 0 || s.U > 1 {
+		return fmt.Errorf("stp: u must be in (0, 1], got %v", s.U)
+	}
+	if s.TauRec <= 0 {
+		return fmt.Errorf("stp: tauRec must be positive, got %v", s.TauRec)
+	}
+	if s.TauFac < 0 {
+		return fmt.Errorf("stp: tauFac must not be negative, got %v", s.TauFac)
+	}
+	return nil
+}
+
+// Efficacy is the factor the weight is multiplied by for the next spike
+func (s *ShortTermPlasticity) Efficacy() float64 {
+	return s.Utilization * s.Resources / s.U
+}
+
+// step lets the state relax for a step of dt milliseconds, then releases
+// resources for the input and returns the efficacy the input is transmitted
+// with
+func (s *ShortTermPlasticity) step(input, dt float64) float64 {
+	s.Resources = 1 - (1-s.Resources)*math.Exp(-dt/s.TauRec)
+	if s.TauFac > 0 {
+		s.Utilization = s.U + (s.Utilization-s.U)*math.Exp(-dt/s.TauFac)
+	} else {
+		s.Utilization = s.U
+	}
+
+	efficacy := s.Efficacy()
+	if input > 0 {
+		p := math.Min(input, 1)
+		s.Resources -= p * s.Utilization * s.Resources
+		if s.TauFac > 0 {
+			s.Utilization += p * s.U * (1 - s.Utilization)
+		}
+	}
+	return efficacy
+}
+
+// SetShortTermPlasticity gives every input connection of the layer its own
+// copy of s, starting at rest
+func (l *Layer) SetShortTermPlasticity(s ShortTermPlasticity) {
+	for i := range l.Neurons {
+		n := &l.Neurons[i]
+		for c := range n.Connections {
+			n.Connections[c].STP = NewShortTermPlasticity(s.U, s.TauRec, s.TauFac)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+)
+
+// train returns the efficacy of each spike of a regular train, one every
+// interval milliseconds
+func train(s *ShortTermPlasticity, spikes int, interval float64) []float64 {
+	efficacies := make([]float64, spikes)
+	for i := range efficacies {
+		efficacies[i] = s.step(1, interval)
+	}
+	return efficacies
+}
+
+func TestShortTermDepression(t *testing.T) {
+	s := Depressing()
+	e := train(s, 5, 10)
+	if e[0] != 1 {
+		t.Errorf("first spike at rest transmitted with %v, want 1", e[0])
+	}
+	// Half of the resources are gone after the first spike and recover for 10 ms
+	if want := 1 - 0.5*math.Exp(-10.0/80); math.Abs(e[1]-want) > 1e-12 {
+		t.Errorf("second spike efficacy %v, want %v", e[1], want)
+	}
+	for i := 1; i < len(e); i++ {
+		if e[i] >= e[i-1] {
+			t.Errorf("efficacies %v, want them to fall spike after spike", e)
+			break
+		}
+	}
+	if s.Utilization != s.U {
+		t.Errorf("utilization %v of a depressing synapse, want it fixed at %v", s.Utilization, s.U)
+	}
+
+	// Resources recover during a long pause
+	if e := s.step(0, 1000); math.Abs(e-1) > 1e-4 {
+		t.Errorf("efficacy %v after a second of rest, want close to 1", e)
+	}
+}
+
+func TestShortTermFacilitation(t *testing.T) {
+	s := Facilitating()
+	e := train(s, 5, 10)
+	// The first spikes facilitate until depletion of the resources takes over
+	if !(e[0] < e[1] && e[1] < e[2] && e[4] < e[2]) {
+		t.Errorf("efficacies %v, want them to rise over three spikes, then fall", e)
+	}
+	// u jumps by U(1-u) and decays for 10 ms; x loses u·x and recovers
+	u := 0.16 + 0.16*(1-0.16)
+	x := 1 - 0.16
+	u = 0.16 + (u-0.16)*math.Exp(-10.0/376)
+	x = 1 - (1-x)*math.Exp(-10.0/45)
+	if want := u * x / 0.16; math.Abs(e[1]-want) > 1e-12 {
+		t.Errorf("second spike efficacy %v, want %v", e[1], want)
+	}
+
+	// A partial input releases and facilitates in proportion
+	half, full := Facilitating(), Facilitating()
+	half.step(0.5, 1)
+	full.step(1, 1)
+	if got, want := 1-half.Resources, (1-full.Resources)/2; math.Abs(got-want) > 1e-12 {
+		t.Errorf("input 0.5 released %v, want half of a full spike's %v", got, 2*want)
+	}
+}
+
+func TestSetShortTermPlasticity(t *testing.T) {
+	layer := NewLayer([]SpikingNeuron{*NewSpikingNeuron(2, 1, 0.9, 0, 0), *NewSpikingNeuron(2, 1, 0.9, 0, 0)})
+	layer.SetShortTermPlasticity(*Depressing())
+	layer.SetNoise(Noise{Kind: NoNoise})
+	if _, err := layer.Forward([]float64{1, 0}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	for j, n := range layer.Neurons {
+		if n.Connections[0].STP.Resources >= 1 || n.Connections[1].STP.Resources != 1 {
+			t.Errorf("neuron %d: resources %v and %v, want only the active input depleted", j, n.Connections[0].STP.Resources, n.Connections[1].STP.Resources)
+		}
+	}
+	if layer.Neurons[0].Connections[0].STP == layer.Neurons[1].Connections[0].STP {
+		t.Error("connections share one synapse state")
+	}
+}
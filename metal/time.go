@@ -0,0 +1,65 @@
+package neuron
+
+import (
+	"fmt"
+	"math"
+)
+
+// Time in the simulation is in milliseconds. Parameters are given per
+// millisecond or as time constants in milliseconds and converted to the
+// network's timestep DT when it runs, so that a model behaves the same at
+// any resolution: decays such as Decay are the fraction kept per millisecond,
+// durations such as RefractoryPeriod are rounded to whole steps, and rates
+// and constant currents such as Bias are scaled by the timestep. Spikes
+// arrive within a single step whatever its length. With DT unset a step is
+// one millisecond, which is how all earlier networks ran.
+
+// DefaultDT is the timestep of a network whose DT is unset, in milliseconds
+const DefaultDT = 1.0
+
+// TimeStep returns the length of a step in milliseconds
+func (n *Network) TimeStep() float64 {
+	if n.DT == 0 {
+		return DefaultDT
+	}
+	return n.DT
+}
+
+// Steps returns the number of steps that cover ms milliseconds, rounded
+func (n *Network) Steps(ms float64) int {
+	return steps(ms, n.TimeStep())
+}
+
+// TauDecay returns the Decay of a membrane with time constant tau in
+// milliseconds
+func TauDecay(tau float64) float64 {
+	return math.Exp(-1 / tau)
+}
+
+func validateDT(dt float64) error {
+	if dt < 0 || math.IsNaN(dt) || math.IsInf(dt, 0) {
+		return fmt.Errorf("timestep must be a positive number of milliseconds, got %v", dt)
+	}
+	return nil
+}
+
+// steps converts a duration in milliseconds to whole steps of dt
+func steps(ms, dt float64) int {
+	return int(math.Round(ms / dt))
+}
+
+// kept converts a fraction kept per millisecond to the fraction kept per step
+func kept(perMS, dt float64) float64 {
+	if dt == 1 {
+		return perMS
+	}
+	return math.Pow(perMS, dt)
+}
+
+// chance converts a probability per millisecond to a probability per step
+func chance(perMS, dt float64) float64 {
+	if dt == 1 {
+		return perMS
+	}
+	return 1 - math.Pow(1-perMS, dt)
+}
@@ -0,0 +1,210 @@
+package neuron
+
+import (
+	"math"
+	"testing"
+
+	"tinybrain/initializer"
+)
+
+// membrane returns the potential of a neuron driven by a constant bias after
+// ms milliseconds at timestep dt, without noise or spikes
+func membrane(dt, ms float64) float64 {
+	n := NewSpikingNeuron(0, 100, TauDecay(10), 0.05, 0)
+	for step := 0; step < int(math.Round(ms/dt)); step++ {
+		n.forward(nil, nil, step, 0, dt, 0, 0, nil)
+	}
+	return n.MembranePotential
+}
+
+func TestTimeStepMembraneConverges(t *testing.T) {
+	// dv/dt = -v/tau + bias, from rest
+	exact := 0.05 * 10 * (1 - math.Exp(-30.0/10))
+	previous := math.Inf(1)
+	for _, dt := range []float64{1, 0.5, 0.1, 0.01} {
+		err := math.Abs(membrane(dt, 30)-exact) / exact
+		if err >= previous {
+			t.Errorf("dt %v: relative error %.4f did not shrink from %.4f", dt, err, previous)
+		}
+		previous = err
+	}
+	if previous > 0.001 {
+		t.Errorf("dt 0.01: relative error %.4f from the continuous solution", previous)
+	}
+}
+
+// firingRate returns the spikes per millisecond of a layer of neurons driven
+// by a constant bias, over ms milliseconds at timestep dt
+func firingRate(t *testing.T, dt, ms float64) float64 {
+	t.Helper()
+	net, err := NewBuilder(1).Seed(7).TimeStep(dt).
+		Layer("out", 20).Decay(initializer.Constant(TauDecay(10))).Bias(initializer.Uniform(0.12, 0.2)).RefractoryPeriod(initializer.Constant(2)).Done().
+		Project(Input, "out", AllToAll{}, initializer.Constant(0)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := net.Run(net.Steps(ms), func(int) []float64 { return []float64{0} }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spikes := 0
+	for _, output := range outputs {
+		for _, s := range output {
+			spikes += s
+		}
+	}
+	return float64(spikes) / 20 / ms
+}
+
+func TestTimeStepFiringRateConverges(t *testing.T) {
+	coarse, fine, finest := firingRate(t, 1, 2000), firingRate(t, 0.1, 2000), firingRate(t, 0.02, 2000)
+	t.Logf("rates at dt 1, 0.1 and 0.02: %.4f %.4f %.4f", coarse, fine, finest)
+	if math.Abs(fine-finest) >= math.Abs(coarse-finest) {
+		t.Errorf("rate at dt 0.1 (%.4f) is no closer than at dt 1 (%.4f) to dt 0.02 (%.4f)", fine, coarse, finest)
+	}
+	if math.Abs(fine-finest)/finest > 0.03 {
+		t.Errorf("rate %.4f at dt 0.1 differs from %.4f at dt 0.02", fine, finest)
+	}
+	if math.Abs(coarse-finest)/finest > 0.2 {
+		t.Errorf("rate %.4f at dt 1 differs from %.4f at dt 0.02", coarse, finest)
+	}
+}
+
+func TestTimeStepDefaultIsOneMillisecond(t *testing.T) {
+	run := func(dt float64) [][]int {
+		net, err := NewBuilder(1).Seed(3).
+			Layer("out", 5).Bias(initializer.Uniform(0.1, 0.3)).RefractoryPeriod(initializer.Constant(2)).Done().
+			Project(Input, "out", AllToAll{}, initializer.Constant(0)).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		net.DT = dt
+		outputs, err := net.Run(200, func(int) []float64 { return []float64{0} }, 0.01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return outputs
+	}
+	unset, one := run(0), run(1)
+	for step := range unset {
+		for j := range unset[step] {
+			if unset[step][j] != one[step][j] {
+				t.Fatalf("step %d neuron %d: %d with dt unset, %d with dt 1", step, j, unset[step][j], one[step][j])
+			}
+		}
+	}
+}
+
+// depressed returns the weights of an untyped and an excitatory connection
+// onto a silent neuron after ms milliseconds of input at timestep dt, with
+// learning enabled
+func depressed(dt, ms float64) (float64, float64) {
+	n := NewSpikingNeuron(0, 1000, TauDecay(10), 0, 0)
+	n.Connections = []Connection{{Weight: 0.5}, {Weight: 0.5, Pre: Excitatory}}
+	for step := 0; step < int(math.Round(ms/dt)); step++ {
+		n.forward([]float64{1, 1}, nil, step, 0.5, dt, 0, 0, nil)
+	}
+	return n.Connections[0].Weight, n.Connections[1].Weight
+}
+
+func TestTimeStepLearningConverges(t *testing.T) {
+	untyped, typed := depressed(0.01, 20)
+	if untyped > 0 || typed > 0.25 {
+		t.Fatalf("weights %.4f and %.4f barely depressed from 0.5", untyped, typed)
+	}
+	for _, dt := range []float64{1, 0.5, 0.1} {
+		u, e := depressed(dt, 20)
+		if math.Abs(u-untyped) > 0.05*dt || math.Abs(e-typed) > 0.05*dt {
+			t.Errorf("dt %v: weights %.4f and %.4f, %.4f and %.4f at dt 0.01", dt, u, e, untyped, typed)
+		}
+	}
+}
+
+// potentiated returns the weight of a held input onto a neuron firing from
+// its bias, and its spike count, after ms milliseconds at timestep dt with
+// learning enabled. The input is held at dt per step, one unit per ms.
+func potentiated(dt, ms float64) (float64, int) {
+	n := NewSpikingNeuron(1, 1, TauDecay(10), 0.5, 2)
+	n.Connections[0].Weight = 0
+	spikes := 0
+	for step := 0; step < int(math.Round(ms/dt)); step++ {
+		spikes += n.forward([]float64{dt}, nil, step, 0.02, dt, 0, 0, nil)
+	}
+	return n.Connections[0].Weight, spikes
+}
+
+func TestTimeStepPotentiationConverges(t *testing.T) {
+	weight, spikes := potentiated(0.01, 100)
+	if weight < 0.1 || spikes < 20 {
+		t.Fatalf("weight %.4f after %d spikes barely potentiated", weight, spikes)
+	}
+	for _, dt := range []float64{1, 0.5, 0.1} {
+		w, s := potentiated(dt, 100)
+		t.Logf("dt %v: weight %.4f after %d spikes", dt, w, s)
+		if math.Abs(w-weight) > 0.2*weight || math.Abs(float64(s-spikes)) > 0.2*float64(spikes) {
+			t.Errorf("dt %v: weight %.4f after %d spikes, %.4f after %d at dt 0.01", dt, w, s, weight, spikes)
+		}
+	}
+}
+
+func TestTimeStepSynapsesMatch(t *testing.T) {
+	// A spike every 25 ms; exponential relaxations make the state at each
+	// spike independent of the timestep
+	efficacies := func(dt float64) []float64 {
+		s := Facilitating()
+		var out []float64
+		for step := 0; step < int(math.Round(200/dt)); step++ {
+			input := 0.0
+			if step%int(math.Round(25/dt)) == 0 {
+				input = 1
+			}
+			e := s.step(input, dt)
+			if input > 0 {
+				out = append(out, e)
+			}
+		}
+		return out
+	}
+	coarse, fine := efficacies(1), efficacies(0.1)
+	if len(coarse) != len(fine) {
+		t.Fatalf("%d spikes at dt 1, %d at dt 0.1", len(coarse), len(fine))
+	}
+	for i := range coarse {
+		if math.Abs(coarse[i]-fine[i]) > 1e-9 {
+			t.Errorf("spike %d: efficacy %.6f at dt 1, %.6f at dt 0.1", i, coarse[i], fine[i])
+		}
+	}
+}
+
+func TestTimeStepHomeostasisRate(t *testing.T) {
+	for _, dt := range []float64{1, 0.1} {
+		n := NewSpikingNeuron(0, 1, 0.5, 0, 0)
+		n.Homeostasis = NewHomeostasis(0.5, 10, 0, 0)
+		every := int(math.Round(4 / dt))
+		for i := 0; i < int(math.Round(200/dt)); i++ {
+			n.homeostasis(i%every == 0, dt)
+		}
+		if math.Abs(n.Homeostasis.Rate-0.25) > 0.05 {
+			t.Errorf("dt %v: rate estimate %.3f per ms for one spike every 4 ms", dt, n.Homeostasis.Rate)
+		}
+	}
+}
+
+func TestTimeStepValidate(t *testing.T) {
+	net, err := NewBuilder(1).Layer("out", 1).Done().Project(Input, "out", AllToAll{}, initializer.Constant(1)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.TimeStep() != DefaultDT || net.Steps(10) != 10 {
+		t.Errorf("unset timestep: %v ms, %d steps for 10 ms", net.TimeStep(), net.Steps(10))
+	}
+	net.DT = -0.1
+	if err := net.Validate(); err == nil {
+		t.Error("negative timestep: expected an error")
+	}
+	if _, err := NewBuilder(1).TimeStep(0).Layer("out", 1).Done().Project(Input, "out", AllToAll{}, initializer.Constant(1)).Build(); err == nil {
+		t.Error("zero timestep: expected an error")
+	}
+}
@@ -0,0 +1,100 @@
+package neuron
+
+import (
+	"errors"
+	"fmt"
+)
+
+// InputSize is the width of the input the network reads
+func (n *Network) InputSize() int {
+	if len(n.Layers) == 0 {
+		return 0
+	}
+	return n.Layers[0].InputSize()
+}
+
+// Validate checks the wiring and timestep of the network once, so that
+// Forward can only fail on the input it is given: every layer must have neurons whose
+// connections fit the layer below, recurrent connections must cover the
+// layer, connections may only target existing dendrites and structural
+// plasticity may only rewire layers without fixed connectivity. NewNetwork, Load
+// and the network constructors call it, and Forward before the first step of
+// a network that has not passed it.
+func (n *Network) Validate() error {
+	n.valid = false
+	if len(n.Layers) == 0 {
+		return errors.New("network has no layers")
+	}
+	if err := validateDT(n.DT); err != nil {
+		return err
+	}
+	width := n.InputSize()
+	for l, layer := range n.Layers {
+		if layer == nil || len(layer.Neurons) == 0 {
+			return fmt.Errorf("layer %d has no neurons", l)
+		}
+		if err := layer.check(width); err != nil {
+			return fmt.Errorf("layer %d: %w", l, err)
+		}
+		width = len(layer.Neurons)
+	}
+	n.valid = true
+	return nil
+}
+
+// check reports whether every neuron of the layer can read an input of the
+// given width
+func (l *Layer) check(inputs int) error {
+	if l.Inputs > 0 && l.Inputs != inputs {
+		return fmt.Errorf("layer reads %d inputs, got %d", l.Inputs, inputs)
+	}
+	if l.Conv != nil && (l.Conv.Channels < 1 || len(l.Neurons)%l.Conv.Channels != 0) {
+		return fmt.Errorf("%d neurons do not split into %d channels", len(l.Neurons), l.Conv.Channels)
+	}
+	if l.Structural != nil {
+		if l.Conv != nil || l.Pool != nil {
+			return errors.New("structural plasticity would break the fixed connectivity of a convolutional or pooling layer")
+		}
+		if err := l.Structural.Validate(); err != nil {
+			return err
+		}
+	}
+	for j := range l.Neurons {
+		if err := l.Neurons[j].check(inputs, len(l.Neurons)); err != nil {
+			return fmt.Errorf("neuron %d: %w", j, err)
+		}
+	}
+	return nil
+}
+
+// check reports whether the neuron can read an input of the given width in a
+// layer of the given size, and whether its noise and escape model are valid
+func (n *SpikingNeuron) check(inputs, layer int) error {
+	if err := n.checkInputs(inputs); err != nil {
+		return err
+	}
+	if len(n.Recurrent) > 0 && len(n.Recurrent) != layer {
+		return fmt.Errorf("%d recurrent connections in a layer of %d", len(n.Recurrent), layer)
+	}
+	if n.Noise != nil {
+		if err := n.Noise.Validate(); err != nil {
+			return err
+		}
+	}
+	if n.Escape != nil {
+		if err := n.Escape.Validate(); err != nil {
+			return err
+		}
+	}
+	for i, c := range n.Connections {
+		if c.Target < 0 || c.Target > len(n.Dendrites) {
+			return fmt.Errorf("connection %d targets compartment %d of %d", i, c.Target, len(n.Dendrites))
+		}
+	}
+	for i, c := range n.Recurrent {
+		if c.Target < 0 || c.Target > len(n.Dendrites) {
+			return fmt.Errorf("recurrent connection %d targets compartment %d of %d", i, c.Target, len(n.Dendrites))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package neuron
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wired returns a layer of size neurons reading inputs values each
+func wired(inputs, size int) *Layer {
+	neurons := make([]SpikingNeuron, size)
+	for j := range neurons {
+		neurons[j] = *NewSpikingNeuron(inputs, 0.5, 0.9, 0, 0)
+	}
+	layer := NewLayer(neurons)
+	layer.SetNoise(Noise{Kind: NoNoise})
+	return layer
+}
+
+// state returns the saved state of a layer
+func state(t *testing.T, l *Layer) string {
+	t.Helper()
+	data, err := json.Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNewNetworkValidates(t *testing.T) {
+	net, err := NewNetwork([]*Layer{wired(3, 5), wired(5, 2), wired(2, 4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := net.Forward([]float64{1, 1, 1}, 0, 0)
+	if err != nil || len(out) != 4 {
+		t.Fatalf("layers of 5, 2 and 4 neurons: output %v, error %v", out, err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		layers func() []*Layer
+		want   string
+	}{
+		{"no layers", func() []*Layer { return nil }, "no layers"},
+		{"nil layer", func() []*Layer { return []*Layer{wired(2, 2), nil} }, "layer 1 has no neurons"},
+		{"empty layer", func() []*Layer { return []*Layer{wired(2, 2), NewLayer(nil)} }, "layer 1 has no neurons"},
+		{"width mismatch", func() []*Layer { return []*Layer{wired(2, 3), wired(2, 1)} }, "layer 1"},
+		{"ragged layer", func() []*Layer {
+			l := wired(2, 2)
+			l.Neurons[1].Connections = l.Neurons[1].Connections[:1]
+			return []*Layer{l}
+		}, "neuron 1"},
+		{"recurrent mismatch", func() []*Layer {
+			l := wired(2, 3)
+			l.Neurons[0].Recurrent = make([]Connection, 2)
+			return []*Layer{l}
+		}, "2 recurrent connections in a layer of 3"},
+		{"missing dendrite", func() []*Layer {
+			l := wired(2, 1)
+			l.Neurons[0].Connections[1].Target = 1
+			return []*Layer{l}
+		}, "targets compartment 1 of 0"},
+	} {
+		net, err := NewNetwork(tc.layers())
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tc.name, err, tc.want)
+		}
+		if net != nil {
+			t.Errorf("%s: returned a network with the error", tc.name)
+		}
+	}
+}
+
+// saved writes the network's state to a file without validating it and
+// returns the path
+func saved(t *testing.T, net *Network) string {
+	t.Helper()
+	data, err := json.Marshal(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "net.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadValidates(t *testing.T) {
+	net, err := NewNetwork([]*Layer{wired(3, 2), wired(2, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	net.Layers[1].Neurons[0].Connections = net.Layers[1].Neurons[0].Connections[:1]
+	loaded := &Network{}
+	if err := loaded.Load(saved(t, net)); err == nil || !strings.Contains(err.Error(), "layer 1") {
+		t.Errorf("loading a miswired network: got %v", err)
+	}
+}
+
+func TestForwardDoesNotAdvanceInvalidNetwork(t *testing.T) {
+	first, second := wired(2, 3), wired(2, 1) // second should read 3 inputs
+	first.Neurons[0].MembranePotential = 0.2
+	net := &Network{Layers: []*Layer{first, second}}
+	steps := 0
+	net.OnStepStart(func(*Network, int) { steps++ })
+
+	before := state(t, first)
+	if _, err := net.Forward([]float64{1, 1}, 0, 0.1); err == nil || !strings.Contains(err.Error(), "layer 1") {
+		t.Fatalf("hand-assembled miswired network: got %v", err)
+	}
+	if steps != 0 || before != state(t, first) {
+		t.Error("the first layer ran before the wiring error was reported")
+	}
+
+	// Fixed by hand, the network is validated again before it runs
+	net.Layers[1] = wired(3, 1)
+	if _, err := net.Forward([]float64{1, 1}, 0, 0.1); err != nil {
+		t.Fatal(err)
+	}
+	if steps != 1 {
+		t.Errorf("%d steps started, want 1", steps)
+	}
+
+	// Breaking a validated network needs Validate to be noticed
+	net.Layers[1].Neurons[0].Connections = nil
+	if err := net.Validate(); err == nil {
+		t.Fatal("expected Validate to fail")
+	}
+	if _, err := net.Forward([]float64{1, 1}, 1, 0.1); err == nil {
+		t.Error("forward after a failed Validate: expected an error")
+	}
+}
+
+func TestForwardInputWidth(t *testing.T) {
+	net, err := NewNetwork([]*Layer{wired(3, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := state(t, net.Layers[0])
+	for _, input := range [][]float64{nil, {1, 1}, {1, 1, 1, 1}} {
+		if _, err := net.Forward(input, 0, 0.1); err == nil || !strings.Contains(err.Error(), "network reads 3") {
+			t.Errorf("input of %d values: got %v", len(input), err)
+		}
+	}
+	if before != state(t, net.Layers[0]) {
+		t.Error("a rejected input changed the layer")
+	}
+	if _, err := (&Network{}).Forward(nil, 0, 0); err == nil {
+		t.Error("network without layers: expected an error")
+	}
+}
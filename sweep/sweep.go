@@ -0,0 +1,278 @@
+// Package sweep runs hyperparameter sweeps: many short experiments with
+// varied parameters, trained in parallel and ranked by how well each network
+// separates the input patterns.
+package sweep
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+
+	"tinybrain/experiment"
+	"tinybrain/initializer"
+	"tinybrain/utils"
+)
+
+// Param is one swept parameter. With Values it is a grid axis; otherwise it
+// is drawn uniformly from [Min, Max) for each random sample. Integer
+// parameters take the floor of the value.
+//
+// Known names are threshold, decay, refractoryPeriod, bias and weight (set as
+// a constant on every layer), neurons (size of every layer) and learningRate.
+type Param struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values,omitempty"`
+	Min    float64   `json:"min,omitempty"`
+	Max    float64   `json:"max,omitempty"`
+}
+
+// Sweep describes the trials to run on top of a base experiment spec
+type Sweep struct {
+	Params  []Param `json:"params"`
+	Samples int     `json:"samples"` // random draws per grid point; 0 means 1
+	Trials  int     `json:"trials"`  // presentations of each pattern when evaluating
+	Workers int     `json:"workers"` // parallel trials; 0 means one per CPU
+	Seed    int64   `json:"seed"`    // seed for random draws and trial networks; 0 seeds from the clock
+}
+
+// Trial is one point of the sweep
+type Trial struct {
+	ID     int                `json:"id"`
+	Seed   int64              `json:"seed"`
+	Params map[string]float64 `json:"params"`
+}
+
+// Result is the outcome of a trial
+type Result struct {
+	Trial
+	utils.ClassificationResult
+	Err error `json:"-"`
+}
+
+var params = map[string]func(spec *experiment.Spec, v float64){
+	"threshold": func(spec *experiment.Spec, v float64) {
+		eachLayer(spec, func(l *experiment.LayerSpec) { l.Threshold = initializer.Constant(v) })
+	},
+	"decay": func(spec *experiment.Spec, v float64) {
+		eachLayer(spec, func(l *experiment.LayerSpec) { l.Decay = initializer.Constant(v) })
+	},
+	"refractoryPeriod": func(spec *experiment.Spec, v float64) {
+		eachLayer(spec, func(l *experiment.LayerSpec) { l.RefractoryPeriod = initializer.Constant(math.Floor(v)) })
+	},
+	// The bias bounds widen to hold the swept value, which would otherwise be
+	// clamped to them
+	"bias": func(spec *experiment.Spec, v float64) {
+		eachLayer(spec, func(l *experiment.LayerSpec) {
+			l.Bias = initializer.Constant(v)
+			l.MinBias, l.MaxBias = min(l.MinBias, v), max(l.MaxBias, v)
+		})
+	},
+	"weight": func(spec *experiment.Spec, v float64) {
+		eachLayer(spec, func(l *experiment.LayerSpec) { l.Weight = initializer.Constant(v) })
+	},
+	"neurons": func(spec *experiment.Spec, v float64) {
+		eachLayer(spec, func(l *experiment.LayerSpec) { l.Neurons = int(v) })
+	},
+	"learningRate": func(spec *experiment.Spec, v float64) {
+		spec.Learning.Rate = v
+	},
+}
+
+func eachLayer(spec *experiment.Spec, fn func(l *experiment.LayerSpec)) {
+	for i := range spec.Layers {
+		fn(&spec.Layers[i])
+	}
+}
+
+// Validate checks the sweep against a base spec
+func (s *Sweep) Validate(base *experiment.Spec) error {
+	if len(s.Params) == 0 {
+		return fmt.Errorf("sweep: no params")
+	}
+	for _, p := range s.Params {
+		if _, ok := params[p.Name]; !ok {
+			return fmt.Errorf("sweep: unknown param %q", p.Name)
+		}
+		if len(p.Values) == 0 && p.Min > p.Max {
+			return fmt.Errorf("sweep: param %s has min %v above max %v", p.Name, p.Min, p.Max)
+		}
+	}
+	if s.Trials < 2 {
+		return fmt.Errorf("sweep: trials must be at least 2, got %d", s.Trials)
+	}
+	if len(base.Input.Patterns) < 2 {
+		return fmt.Errorf("sweep: the base spec needs two patterns to evaluate")
+	}
+	return base.Validate()
+}
+
+// Expand lists every trial: the cartesian product of the grid params, with
+// Samples random draws of the range params at each grid point
+func (s *Sweep) Expand() []Trial {
+	seed := s.Seed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	points := []map[string]float64{{}}
+	for _, p := range s.Params {
+		if len(p.Values) == 0 {
+			continue
+		}
+		var next []map[string]float64
+		for _, point := range points {
+			for _, v := range p.Values {
+				extended := make(map[string]float64, len(point)+1)
+				for k, x := range point {
+					extended[k] = x
+				}
+				extended[p.Name] = v
+				next = append(next, extended)
+			}
+		}
+		points = next
+	}
+
+	var trials []Trial
+	for _, point := range points {
+		for n := 0; n < max(s.Samples, 1); n++ {
+			values := make(map[string]float64, len(s.Params))
+			for _, p := range s.Params {
+				if len(p.Values) > 0 {
+					values[p.Name] = point[p.Name]
+				} else {
+					values[p.Name] = p.Min + rng.Float64()*(p.Max-p.Min)
+				}
+			}
+			trials = append(trials, Trial{ID: len(trials), Seed: rng.Int63(), Params: values})
+		}
+	}
+	return trials
+}
+
+// Run trains a fresh network for every trial on a pool of workers and returns
+// the results ranked best first. Trials that fail keep their error in Err and
+// rank last. Run stops starting new trials when ctx is cancelled.
+func (s *Sweep) Run(ctx context.Context, base *experiment.Spec, trials []Trial) []Result {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan Trial)
+	results := make([]Result, len(trials))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trial := range jobs {
+				results[trial.ID] = s.runTrial(base, trial)
+			}
+		}()
+	}
+
+feed:
+	for _, trial := range trials {
+		select {
+		case jobs <- trial:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := range results {
+		if results[i].Params == nil {
+			results[i] = Result{Trial: trials[i], Err: ctx.Err()}
+		}
+	}
+	Rank(results)
+	return results
+}
+
+func (s *Sweep) runTrial(base *experiment.Spec, trial Trial) Result {
+	result := Result{Trial: trial}
+
+	spec := base.Clone()
+	spec.Seed = trial.Seed
+	for name, v := range trial.Params {
+		params[name](spec, v)
+	}
+
+	rng := spec.NewRand()
+	net, err := spec.Build(rng)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	plan := spec.Plan(rng)
+	for run := 0; run < plan.Runs; run++ {
+		for t := 0; t < plan.Steps; t++ {
+			if _, err := net.Forward(plan.Pattern(t).Values, t, plan.LearningRate); err != nil {
+				result.Err = err
+				return result
+			}
+		}
+	}
+
+	patterns := plan.Patterns()
+	result.ClassificationResult, result.Err = utils.EvaluateClassification(net, patterns[0].Values, patterns[1].Values, s.Trials)
+	return result
+}
+
+// Rank sorts results best first: by separation score, then by mean
+// consistency. Failed trials go last.
+func Rank(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if a.SeparationScore != b.SeparationScore {
+			return a.SeparationScore > b.SeparationScore
+		}
+		return a.ConsistencyA+a.ConsistencyB > b.ConsistencyA+b.ConsistencyB
+	})
+}
+
+// WriteCSV writes the ranked results table, one column per swept parameter
+func WriteCSV(w io.Writer, sweep *Sweep, results []Result) error {
+	out := csv.NewWriter(w)
+	header := []string{"rank", "trial", "seed"}
+	for _, p := range sweep.Params {
+		header = append(header, p.Name)
+	}
+	header = append(header, "separationScore", "consistencyA", "consistencyB", "patternDifferentiation", "error")
+	out.Write(header)
+
+	for rank, r := range results {
+		row := []string{strconv.Itoa(rank + 1), strconv.Itoa(r.ID), strconv.FormatInt(r.Seed, 10)}
+		for _, p := range sweep.Params {
+			row = append(row, strconv.FormatFloat(r.Params[p.Name], 'g', 6, 64))
+		}
+		errText := ""
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		row = append(row,
+			strconv.FormatFloat(r.SeparationScore, 'f', 4, 64),
+			strconv.FormatFloat(r.ConsistencyA, 'f', 4, 64),
+			strconv.FormatFloat(r.ConsistencyB, 'f', 4, 64),
+			strconv.FormatBool(r.PatternDifferentiation),
+			errText,
+		)
+		out.Write(row)
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,135 @@
+package sweep
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tinybrain/experiment"
+	"tinybrain/initializer"
+	"tinybrain/utils"
+)
+
+func TestExpand(t *testing.T) {
+	s := &Sweep{
+		Params: []Param{
+			{Name: "threshold", Values: []float64{0.8, 1.2}},
+			{Name: "neurons", Values: []float64{4, 8, 16}},
+			{Name: "decay", Min: 0.6, Max: 0.9},
+		},
+		Samples: 2,
+		Seed:    5,
+	}
+	trials := s.Expand()
+	if len(trials) != 2*3*2 {
+		t.Fatalf("%d trials, want 2x3 grid points with 2 samples each", len(trials))
+	}
+	grid := map[[2]float64]int{}
+	for i, trial := range trials {
+		if trial.ID != i {
+			t.Errorf("trial %d has ID %d", i, trial.ID)
+		}
+		grid[[2]float64{trial.Params["threshold"], trial.Params["neurons"]}]++
+		if d := trial.Params["decay"]; d < 0.6 || d >= 0.9 {
+			t.Errorf("trial %d: decay %v outside [0.6, 0.9)", i, d)
+		}
+	}
+	for _, threshold := range []float64{0.8, 1.2} {
+		for _, neurons := range []float64{4, 8, 16} {
+			if grid[[2]float64{threshold, neurons}] != 2 {
+				t.Errorf("grid point %v, %v appears %d times, want 2", threshold, neurons, grid[[2]float64{threshold, neurons}])
+			}
+		}
+	}
+
+	if again := s.Expand(); !reflect.DeepEqual(trials, again) {
+		t.Error("the same seed expanded to different trials")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	base := experiment.Default()
+	for _, s := range []*Sweep{
+		{Trials: 2},
+		{Params: []Param{{Name: "colour", Values: []float64{1}}}, Trials: 2},
+		{Params: []Param{{Name: "bias", Min: 1, Max: 0}}, Trials: 2},
+		{Params: []Param{{Name: "bias", Values: []float64{0}}}, Trials: 1},
+	} {
+		if err := s.Validate(base); err == nil {
+			t.Errorf("%+v: expected an error", s)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	failed := errors.New("failed")
+	results := []Result{
+		{Trial: Trial{ID: 0}, Err: failed},
+		{Trial: Trial{ID: 1}, ClassificationResult: utils.ClassificationResult{SeparationScore: 0.5, ConsistencyA: 0.5, ConsistencyB: 0.5}},
+		{Trial: Trial{ID: 2}, ClassificationResult: utils.ClassificationResult{SeparationScore: 0.9}},
+		{Trial: Trial{ID: 3}, ClassificationResult: utils.ClassificationResult{SeparationScore: 0.5, ConsistencyA: 1, ConsistencyB: 1}},
+		{Trial: Trial{ID: 4}, ClassificationResult: utils.ClassificationResult{SeparationScore: 2}, Err: failed},
+	}
+	Rank(results)
+	var order []int
+	for _, r := range results[:3] {
+		order = append(order, r.ID)
+	}
+	if want := []int{2, 3, 1}; !reflect.DeepEqual(order, want) {
+		t.Errorf("ranked %v first, want %v", order, want)
+	}
+	if results[3].Err == nil || results[4].Err == nil {
+		t.Error("failed trials should rank last, whatever their score")
+	}
+}
+
+func TestRun(t *testing.T) {
+	base := experiment.Default()
+	base.Run.Steps = 20
+	s := &Sweep{Params: []Param{{Name: "threshold", Values: []float64{0.8, 1.2}}, {Name: "neurons", Values: []float64{4, 0}}}, Trials: 2, Workers: 2, Seed: 1}
+	trials := s.Expand()
+	results := s.Run(context.Background(), base, trials)
+	if len(results) != len(trials) {
+		t.Fatalf("%d results for %d trials", len(results), len(trials))
+	}
+	for rank, r := range results {
+		if failed := r.Params["neurons"] == 0; (r.Err != nil) != failed || failed != (rank >= 2) {
+			t.Errorf("rank %d: trial %+v failed with %v; only the trials without neurons should, ranked last", rank, r.Params, r.Err)
+		}
+	}
+	if base.Layers[0].Neurons != 8 || base.Layers[0].Threshold != initializer.Uniform(1.0, 1.5) {
+		t.Error("running the sweep changed the base spec")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, s, results); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(rows) != 5 || !strings.HasPrefix(rows[0], "rank,trial,seed,threshold,neurons,") {
+		t.Errorf("csv:\n%s", buf.String())
+	}
+
+}
+
+func TestBiasParam(t *testing.T) {
+	for _, v := range []float64{0.7, -2} {
+		spec := experiment.Default()
+		params["bias"](spec, v)
+		net, err := spec.Build(rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for l, layer := range net.Layers {
+			for j, n := range layer.Neurons {
+				if n.Bias != v {
+					t.Fatalf("layer %d neuron %d: bias %v, want the swept %v", l, j, n.Bias, v)
+				}
+			}
+		}
+	}
+}
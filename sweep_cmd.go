@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"tinybrain/experiment"
+	"tinybrain/sweep"
+)
+
+type sweepEvent struct {
+	Event string `json:"event"`
+	Rank  int    `json:"rank"`
+	sweep.Result
+	Error string `json:"error,omitempty"`
+}
+
+func runSweep(args []string) error {
+	fs, spec, err := newFlagSet("sweep", args)
+	if err != nil {
+		return err
+	}
+	sweepPath := fs.String("sweep", "", "sweep file (.json, .yaml or .yml) with params to vary")
+	workers := fs.Int("workers", 0, "parallel trials (0 uses the sweep file, then one per CPU)")
+	out := fs.String("out", "sweep_results.csv", "ranked results table")
+	top := fs.Int("top", 5, "number of best trials to emit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sweepPath == "" {
+		return fmt.Errorf("sweep needs -sweep")
+	}
+
+	s := &sweep.Sweep{Trials: 20}
+	if err := experiment.ReadFile(*sweepPath, s); err != nil {
+		return err
+	}
+	if *workers > 0 {
+		s.Workers = *workers
+	}
+	if err := s.Validate(spec); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	trials := s.Expand()
+	emit(struct {
+		Event  string `json:"event"`
+		Trials int    `json:"trials"`
+	}{"sweep", len(trials)})
+	results := s.Run(ctx, spec, trials)
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := sweep.WriteCSV(file, s, results); err != nil {
+		return err
+	}
+
+	for rank, r := range results[:min(*top, len(results))] {
+		e := sweepEvent{Event: "trial", Rank: rank + 1, Result: r}
+		if r.Err != nil {
+			e.Error = r.Err.Error()
+		}
+		emit(e)
+	}
+	emit(pathEvent{"results", *out})
+	return ctx.Err()
+}
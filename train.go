@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"tinybrain/experiment"
+	neuron "tinybrain/metal"
+
+	"github.com/muesli/termenv"
+)
+
+type stepEvent struct {
+	Event   string `json:"event"`
+	Run     int    `json:"run"`
+	T       int    `json:"t"`
+	Pattern string `json:"pattern"`
+	Spikes  int    `json:"spikes"`
+	Output  []int  `json:"output"`
+}
+
+type weightEvent struct {
+	Event  string  `json:"event"`
+	Run    int     `json:"run"`
+	T      int     `json:"t"`
+	Probe  string  `json:"probe"`
+	Weight float64 `json:"weight"`
+}
+
+type runSummary struct {
+	Event     string             `json:"event"`
+	Run       int                `json:"run"`
+	AvgSpikes map[string]float64 `json:"avgSpikes"`
+}
+
+func runTrain(args []string) error {
+	fs, spec, err := newFlagSet("train", args)
+	if err != nil {
+		return err
+	}
+	fs.IntVar(&spec.Run.Runs, "runs", spec.Run.Runs, "number of consecutive training runs")
+	fs.StringVar(&spec.Outputs.Potentials, "potentials", spec.Outputs.Potentials, "membrane potential CSV (empty to disable)")
+	fs.StringVar(&spec.Outputs.Spikes, "spikes", spec.Outputs.Spikes, "AER spike file (empty to disable)")
+	live := fs.Bool("live", false, "draw a live spike monitor on stderr")
+	quiet := fs.Bool("quiet", false, "only emit run summaries")
+	trackEvery := fs.Int("track-every", 10, "steps between reports of the tracked weight L0.N0.C0")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	rng := spec.NewRand()
+	net, err := loadNetwork(spec, rng, true)
+	if err != nil {
+		return err
+	}
+	plan := spec.Plan(rng)
+
+	for run := 1; run <= plan.Runs; run++ {
+		r := &trainRun{plan: plan, run: run, quiet: *quiet, trackEvery: *trackEvery}
+		if *live {
+			r.live = os.Stderr
+		}
+		if err := r.execute(net, spec.Outputs.Potentials, spec.Outputs.Spikes); err != nil {
+			return err
+		}
+
+		// Save network state after training
+		if err := net.Save(spec.Outputs.State); err != nil {
+			return fmt.Errorf("saving network: %w", err)
+		}
+		emit(networkEvent{"saved", spec.Outputs.State, len(net.Layers)})
+	}
+	return nil
+}
+
+// trainRun is a single pass of plan.Steps timesteps over the patterns
+type trainRun struct {
+	plan       *experiment.Plan
+	run        int
+	quiet      bool
+	trackEvery int
+	live       io.Writer
+	history    []stepEvent
+}
+
+func (r *trainRun) execute(net *neuron.Network, potentialsPath, spikesPath string) error {
+	monitor := neuron.NewMonitor()
+	if potentialsPath != "" {
+		file, err := os.Create(potentialsPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		monitor = neuron.NewMonitor(neuron.NewCSVSink(file))
+		for l := range net.Layers {
+			monitor.Add(&neuron.Probe{Variable: neuron.Potential, Layer: l, MaxSamples: 1})
+		}
+	}
+	// Track weight of first neuron in first layer from first input
+	tracked := monitor.Add(&neuron.Probe{
+		Name:        "L0.N0.C0",
+		Variable:    neuron.Weight,
+		Neurons:     []int{0},
+		Connections: []int{0},
+		Interval:    r.trackEvery,
+	})
+
+	var recorder *neuron.AERWriter
+	if spikesPath != "" {
+		file, err := os.Create(spikesPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		recorder = neuron.NewAERWriter(file, neuron.AERText)
+	}
+
+	totals := map[string]float64{}
+	counts := map[string]float64{}
+	for t := 0; t < r.plan.Steps; t++ {
+		pattern := r.plan.Pattern(t)
+		output, err := net.Forward(pattern.Values, t, r.plan.LearningRate)
+		if err != nil {
+			return err
+		}
+		if err := monitor.Sample(t, net); err != nil {
+			return err
+		}
+		if recorder != nil {
+			if err := recorder.Record(t, net); err != nil {
+				return err
+			}
+		}
+
+		step := stepEvent{"step", r.run, t, pattern.Label, countSpikes(output), output}
+		totals[pattern.Label] += float64(step.Spikes)
+		counts[pattern.Label]++
+		if !r.quiet {
+			emit(step)
+			if sample, ok := tracked.Last(); ok && sample.Time == t {
+				emit(weightEvent{"weight", r.run, t, sample.Probe, sample.Value})
+			}
+		}
+		if r.live != nil {
+			r.draw(step)
+		}
+	}
+
+	if err := monitor.Flush(); err != nil {
+		return err
+	}
+	if recorder != nil {
+		if err := recorder.Flush(); err != nil {
+			return err
+		}
+	}
+
+	summary := runSummary{"summary", r.run, map[string]float64{}}
+	for label, total := range totals {
+		summary.AvgSpikes[label] = total / counts[label]
+	}
+	emit(summary)
+	return nil
+}
+
+func countSpikes(output []int) int {
+	spikes := 0
+	for _, s := range output {
+		spikes += s
+	}
+	return spikes
+}
+
+// draw redraws the live spike monitor with the last 10 steps
+func (r *trainRun) draw(step stepEvent) {
+	r.history = append(r.history, step)
+	if len(r.history) > 10 {
+		r.history = r.history[1:]
+	}
+
+	p := termenv.ColorProfile()
+	colors := []termenv.Color{p.Color("#4682B4"), p.Color("#DC143C")} // SteelBlue, Crimson
+
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "=== Live Spike Monitor (Run %d) ===\n", r.run)
+	for _, s := range r.history {
+		color := colors[0]
+		for i, label := range r.plan.Labels() {
+			if label == s.Pattern {
+				color = colors[i%len(colors)]
+			}
+		}
+		line := fmt.Sprintf("Step %d (Pattern %s): %s %d spikes", s.T, s.Pattern, strings.Repeat("■", s.Spikes), s.Spikes)
+		b.WriteString(termenv.String(line).Foreground(color).String())
+		b.WriteString("\n")
+	}
+	io.WriteString(r.live, b.String())
+}
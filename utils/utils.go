@@ -14,21 +14,48 @@ import (
 
 // ClassificationResult holds evaluation metrics
 type ClassificationResult struct {
-	PatternDifferentiation bool
-	ConsistencyA           float64
-	ConsistencyB           float64
-	SeparationScore        float64
+	PatternDifferentiation bool    `json:"patternDifferentiation"`
+	ConsistencyA           float64 `json:"consistencyA"`
+	ConsistencyB           float64 `json:"consistencyB"`
+	SeparationScore        float64 `json:"separationScore"`
 }
 
 // CheckClassification evaluates if the network distinguishes between patterns
-func CheckClassification(net *neuron.Network, patternA, patternB []float64, trials int) ClassificationResult {
+// and prints the results
+func CheckClassification(net *neuron.Network, patternA, patternB []float64, trials int) (ClassificationResult, error) {
+	result, err := EvaluateClassification(net, patternA, patternB, trials)
+	if err != nil {
+		return result, err
+	}
+
+	fmt.Printf("\nClassification Results:\n")
+	fmt.Printf("✅ Pattern Differentiation: %v\n", result.PatternDifferentiation)
+	fmt.Printf("📊 Separation Score: %.2f\n", result.SeparationScore)
+	fmt.Printf("🔵 Pattern A Consistency: %.2f\n", result.ConsistencyA)
+	fmt.Printf("🔴 Pattern B Consistency: %.2f\n", result.ConsistencyB)
+
+	return result, nil
+}
+
+// EvaluateClassification measures how well the network distinguishes between
+// patterns without learning (learning rate 0). The patterns must fit the
+// network's input; its output may have any size.
+func EvaluateClassification(net *neuron.Network, patternA, patternB []float64, trials int) (ClassificationResult, error) {
 	var diffSum, outputASum, outputBSum float64
 	outputAHistory := make([][]int, trials)
 	outputBHistory := make([][]int, trials)
 
+	outputs := 0
 	for i := 0; i < trials; i++ {
-		outputA := net.Forward(patternA, i)
-		outputB := net.Forward(patternB, i)
+		outputA, err := net.Forward(patternA, i, 0)
+		if err != nil {
+			return ClassificationResult{}, fmt.Errorf("pattern A: %w", err)
+		}
+		outputB, err := net.Forward(patternB, i, 0)
+		if err != nil {
+			return ClassificationResult{}, fmt.Errorf("pattern B: %w", err)
+		}
+		outputs = len(outputA)
 		outputAHistory[i] = outputA
 		outputBHistory[i] = outputB
 
@@ -40,25 +67,17 @@ func CheckClassification(net *neuron.Network, patternA, patternB []float64, tria
 		}
 	}
 
-	avgDiff := diffSum / float64(trials*len(patternA))
+	avgDiff := diffSum / float64(trials*outputs)
 	consistencyA := calculateConsistency(outputAHistory)
 	consistencyB := calculateConsistency(outputBHistory)
 	separationScore := avgDiff * (consistencyA + consistencyB) / 2
 
-	result := ClassificationResult{
+	return ClassificationResult{
 		PatternDifferentiation: avgDiff > 0.5,
 		ConsistencyA:           consistencyA,
 		ConsistencyB:           consistencyB,
 		SeparationScore:        separationScore,
-	}
-
-	fmt.Printf("\nClassification Results:\n")
-	fmt.Printf("✅ Pattern Differentiation: %v\n", result.PatternDifferentiation)
-	fmt.Printf("📊 Separation Score: %.2f\n", result.SeparationScore)
-	fmt.Printf("🔵 Pattern A Consistency: %.2f\n", consistencyA)
-	fmt.Printf("🔴 Pattern B Consistency: %.2f\n", consistencyB)
-
-	return result
+	}, nil
 }
 
 func calculateConsistency(outputs [][]int) float64 {
@@ -90,7 +109,7 @@ func VisualizeSpikeRaster(spikes [][]int, patterns []string, patternSwitchInterv
 			Title:    "Network Spike Raster",
 			Subtitle: "Each line represents a neuron's activity over time",
 		}),
-		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
 		charts.WithXAxisOpts(opts.XAxis{Name: "Time Step"}),
 		charts.WithYAxisOpts(opts.YAxis{Name: "Neuron Index"}),
 		charts.WithDataZoomOpts(opts.DataZoom{
@@ -210,7 +229,7 @@ func GenerateWeightHeatmap(net *neuron.Network) error {
 				Subtitle: "Input neurons vs Layer neurons",
 			}),
 			charts.WithVisualMapOpts(opts.VisualMap{
-				Calculable: true,
+				Calculable: opts.Bool(true),
 				Min:        -1,
 				Max:        1,
 				InRange:    &opts.VisualMapInRange{Color: []string{"#0000FF", "#FFFFFF", "#FF0000"}},
@@ -222,7 +241,7 @@ func GenerateWeightHeatmap(net *neuron.Network) error {
 		for neuronIdx, neuron := range layer.Neurons {
 			for connIdx, conn := range neuron.Connections {
 				data = append(data, opts.HeatMapData{
-					Value: [3]interface{}{connIdx, neuronIdx, conn.Weight},
+					Value: [3]interface{}{neuron.InputIndex(connIdx), neuronIdx, conn.Weight},
 				})
 			}
 		}